@@ -19,6 +19,8 @@ type Mapper[T any] interface {
 	ModelToDb(*T)
 	MapToDb(map[string]interface{}) map[string]interface{}
 }
+
+// Adapter supports a composite key K only as an _id subdocument. For a composite key of several fields, use repository.Repository with SetCompositeKey.
 type Adapter[T any, K any] struct {
 	Collection   *mongo.Collection
 	Map          map[string]string
@@ -29,6 +31,11 @@ type Adapter[T any, K any] struct {
 	versionJson  string
 	versionBson  string
 	Mapper       Mapper[T]
+	// ReturnError makes the methods return ErrNotFound, ErrDuplicateKey and ErrVersionConflict, instead of nil, 0 and -1
+	ReturnError bool
+	Hooks       *mgo.Hooks[T]
+	idStrategy  string
+	mgo.Options
 }
 
 func FindFieldByName(modelType reflect.Type, fieldName string) (int, string, string) {
@@ -67,6 +74,7 @@ func NewMongoAdapterWithVersion[T any, K any](db *mongo.Database, collectionName
 	}
 	adapter := &Adapter[T, K]{Collection: db.Collection(collectionName), idJson: jsonIdName, idIndex: idIndex, ObjectId: idObjectId,
 		Map: mgo.MakeBsonMap(modelType), Mapper: mapper, versionIndex: -1}
+	adapter.Options = mgo.NewOptions(&adapter.Collection)
 	if len(versionField) > 0 {
 		index, versionJson, versionBson := FindFieldByName(modelType, versionField)
 		if index >= 0 {
@@ -84,6 +92,8 @@ func NewAdapter[T any, K any](db *mongo.Database, collectionName string, options
 	return NewMongoAdapterWithVersion[T, K](db, collectionName, false, "", options...)
 }
 func (a *Adapter[T, K]) All(ctx context.Context) ([]T, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, a.Timeout())
+	defer cancel()
 	filter := bson.M{}
 	cursor, err := a.Collection.Find(ctx, filter)
 	if err != nil {
@@ -100,21 +110,33 @@ func (a *Adapter[T, K]) All(ctx context.Context) ([]T, error) {
 			a.Mapper.DbToModel(&objs[i])
 		}
 	}
+	if err = mgo.RunAfterLoads(ctx, a.Hooks, objs); err != nil {
+		return nil, err
+	}
 	return objs, nil
 }
 func (a *Adapter[T, K]) Load(ctx context.Context, id K) (*T, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, a.Timeout())
+	defer cancel()
 	var res T
 	if a.ObjectId {
-		objId := fmt.Sprintf("%v", id)
-		objectId, err := primitive.ObjectIDFromHex(objId)
+		objectId, err := mgo.ToId(id, true)
 		if err != nil {
 			return nil, err
 		}
 		query := bson.M{"_id": objectId}
 		ok, er0 := mgo.FindOne(ctx, a.Collection, query, &res)
+		if !ok && er0 == nil && a.ReturnError {
+			return nil, mgo.ErrNotFound
+		}
 		if ok && er0 == nil && a.Mapper != nil {
 			a.Mapper.DbToModel(&res)
 		}
+		if ok && er0 == nil {
+			if er1 := mgo.RunAfterLoad(ctx, a.Hooks, &res); er1 != nil {
+				return nil, er1
+			}
+		}
 		return &res, er0
 	}
 	query := bson.M{"_id": id}
@@ -123,18 +145,25 @@ func (a *Adapter[T, K]) Load(ctx context.Context, id K) (*T, error) {
 		return nil, er2
 	}
 	if !ok {
+		if a.ReturnError {
+			return nil, mgo.ErrNotFound
+		}
 		return nil, nil
 	}
 	if a.Mapper != nil {
 		a.Mapper.DbToModel(&res)
 	}
+	if er3 := mgo.RunAfterLoad(ctx, a.Hooks, &res); er3 != nil {
+		return nil, er3
+	}
 	return &res, er2
 }
 
 func (a *Adapter[T, K]) Exist(ctx context.Context, id K) (bool, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, a.Timeout())
+	defer cancel()
 	if a.ObjectId {
-		objId := fmt.Sprintf("%v", id)
-		objectId, err := primitive.ObjectIDFromHex(objId)
+		objectId, err := mgo.ToId(id, true)
 		if err != nil {
 			return false, err
 		}
@@ -143,14 +172,31 @@ func (a *Adapter[T, K]) Exist(ctx context.Context, id K) (bool, error) {
 	return mgo.Exist(ctx, a.Collection, id)
 }
 func (a *Adapter[T, K]) Create(ctx context.Context, model *T) (int64, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, a.Timeout())
+	defer cancel()
+	if err := mgo.RunBeforeCreate(ctx, a.Hooks, model); err != nil {
+		return 0, err
+	}
+	res, err := a.create(ctx, model)
+	if err == nil && res > 0 {
+		if er1 := mgo.RunAfterCreate(ctx, a.Hooks, model); er1 != nil {
+			return res, er1
+		}
+	}
+	return res, err
+}
+func (a *Adapter[T, K]) create(ctx context.Context, model *T) (int64, error) {
 	if a.Mapper != nil {
-		a.Mapper.ModelToDb(model)
+		if err := mgo.ModelToDb[T](a.Mapper, model); err != nil {
+			return 0, err
+		}
 	}
 	vo := reflect.Indirect(reflect.ValueOf(model))
 	if a.versionIndex >= 0 {
 		setVersion(vo, a.versionIndex)
 	}
-	rid, res, err := mgo.InsertOne(ctx, a.Collection, model)
+	a.generateId(vo)
+	rid, res, err := a.insertOne(ctx, model)
 	if err != nil {
 		return res, err
 	}
@@ -174,8 +220,24 @@ func (a *Adapter[T, K]) Create(ctx context.Context, model *T) (int64, error) {
 	return res, err
 }
 func (a *Adapter[T, K]) Update(ctx context.Context, model *T) (int64, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, a.Timeout())
+	defer cancel()
+	if err := mgo.RunBeforeUpdate(ctx, a.Hooks, model); err != nil {
+		return 0, err
+	}
+	res, err := a.update(ctx, model)
+	if err == nil && res > 0 {
+		if er1 := mgo.RunAfterUpdate(ctx, a.Hooks, model); er1 != nil {
+			return res, er1
+		}
+	}
+	return res, err
+}
+func (a *Adapter[T, K]) update(ctx context.Context, model *T) (int64, error) {
 	if a.Mapper != nil {
-		a.Mapper.ModelToDb(model)
+		if err := mgo.ModelToDb[T](a.Mapper, model); err != nil {
+			return 0, err
+		}
 	}
 	vo := reflect.Indirect(reflect.ValueOf(model))
 	id := vo.Field(a.idIndex).Interface()
@@ -190,56 +252,126 @@ func (a *Adapter[T, K]) Update(ctx context.Context, model *T) (int64, error) {
 			return res, err
 		}
 		if res <= 0 {
-			ok, _ := mgo.Exist(ctx, a.Collection, id)
-			if ok {
-				return -1, nil
-			} else {
-				return 0, nil
-			}
+			return a.conflict(ctx, id)
 		}
+		return res, err
 	}
-	return mgo.UpdateOne(ctx, a.Collection, id, model)
+	res, err := mgo.UpdateOne(ctx, a.Collection, id, model)
+	return a.notFound(res, err)
 }
 
 func (a *Adapter[T, K]) Patch(ctx context.Context, model map[string]interface{}) (int64, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, a.Timeout())
+	defer cancel()
+	if err := mgo.RunBeforePatch(ctx, a.Hooks, model); err != nil {
+		return 0, err
+	}
+	return a.patch(ctx, model)
+}
+func (a *Adapter[T, K]) patch(ctx context.Context, model map[string]interface{}) (int64, error) {
 	if a.Mapper != nil {
-		model = a.Mapper.MapToDb(model)
+		m, err := mgo.MapToDb(a.Mapper, model)
+		if err != nil {
+			return 0, err
+		}
+		model = m
 	}
 	id, exist := model[a.idJson]
 	if !exist {
 		return -1, fmt.Errorf("%s must be in map[string]interface{} for patch", a.idJson)
 	}
+	id, err := mgo.MapId[K](id, a.ObjectId)
+	if err != nil {
+		return -1, err
+	}
+	model[a.idJson] = id
 	if a.versionIndex >= 0 {
 		currentVersion, vok := model[a.versionJson]
 		if !vok {
 			return -1, fmt.Errorf("%s must be in model for patch", a.versionJson)
 		}
-		ok := increaseMapVersion(model, a.versionJson, currentVersion)
-		if !ok {
+		var t T
+		versionType := reflect.TypeOf(t).Field(a.versionIndex).Type
+		currentVersion, vok = increaseMapVersion(model, a.versionJson, versionType, currentVersion)
+		if !vok {
 			return -1, errors.New("do not support this version type")
 		}
 		var filter = bson.D{}
 		filter = append(filter, bson.E{Key: "_id", Value: id})
 		filter = append(filter, bson.E{Key: a.versionBson, Value: currentVersion})
 		b := mgo.MapToBson(model, a.Map)
-		return mgo.PatchOneByFilter(ctx, a.Collection, filter, b)
+		res, err := mgo.PatchOneByFilter(ctx, a.Collection, filter, b)
+		if err == nil && res <= 0 && a.ReturnError {
+			return a.conflict(ctx, id)
+		}
+		return res, err
 	}
 	b := mgo.MapToBson(model, a.Map)
-	return mgo.PatchOne(ctx, a.Collection, id, b)
+	res, err := mgo.PatchOne(ctx, a.Collection, id, b)
+	return a.notFound(res, err)
 }
 
 func (a *Adapter[T, K]) Save(ctx context.Context, model *T) (int64, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, a.Timeout())
+	defer cancel()
+	isNew := a.isNew(model)
+	var err error
+	if isNew {
+		err = mgo.RunBeforeCreate(ctx, a.Hooks, model)
+	} else {
+		err = mgo.RunBeforeUpdate(ctx, a.Hooks, model)
+	}
+	if err != nil {
+		return 0, err
+	}
+	res, err := a.save(ctx, model)
+	if err == nil && res > 0 {
+		if isNew {
+			err = mgo.RunAfterCreate(ctx, a.Hooks, model)
+		} else {
+			err = mgo.RunAfterUpdate(ctx, a.Hooks, model)
+		}
+	}
+	return res, err
+}
+func (a *Adapter[T, K]) isNew(model *T) bool {
+	f := reflect.Indirect(reflect.ValueOf(model)).Field(a.idIndex)
+	if mgo.IsCompositeId(f.Type()) {
+		return false
+	}
+	return mgo.IsEmptyId(f.Interface())
+}
+
+// SetIdStrategy sets the strategy to generate the ids of new models: mgo.IdDefault, mgo.IdObjectId or mgo.IdUUID
+func (a *Adapter[T, K]) SetIdStrategy(strategy string) {
+	a.idStrategy = strategy
+}
+func (a *Adapter[T, K]) generateId(vo reflect.Value) {
+	if a.idIndex < 0 {
+		return
+	}
+	f := vo.Field(a.idIndex)
+	if !mgo.IsEmptyId(f.Interface()) {
+		return
+	}
+	if id, ok := mgo.NewId(f.Type(), a.idStrategy); ok {
+		f.Set(reflect.ValueOf(id))
+	}
+}
+func (a *Adapter[T, K]) save(ctx context.Context, model *T) (int64, error) {
 	if a.Mapper != nil {
-		a.Mapper.ModelToDb(model)
+		if err := mgo.ModelToDb[T](a.Mapper, model); err != nil {
+			return 0, err
+		}
 	}
 	vo := reflect.Indirect(reflect.ValueOf(model))
 	id := vo.Field(a.idIndex).Interface()
-	sid, ok := id.(string)
-	if id == nil || ok && len(sid) == 0 {
+	if a.isNew(model) {
 		if a.versionIndex >= 0 {
 			setVersion(vo, a.versionIndex)
 		}
-		rid, res, err := mgo.InsertOne(ctx, a.Collection, model)
+		a.generateId(vo)
+		rid, res, err := a.insertOne(ctx, model)
 		if err != nil {
 			return res, err
 		}
@@ -268,70 +400,108 @@ func (a *Adapter[T, K]) Save(ctx context.Context, model *T) (int64, error) {
 			var filter = bson.D{}
 			filter = append(filter, bson.E{Key: "_id", Value: id})
 			filter = append(filter, bson.E{Key: a.versionBson, Value: currentVersion})
-			return mgo.UpsertOneByFilter(ctx, a.Collection, filter, model)
+			res, err := mgo.UpsertOneByFilter(ctx, a.Collection, filter, model)
+			return a.versionError(res, err)
 		} else {
 			return mgo.UpsertOne(ctx, a.Collection, id, model)
 		}
 	}
 }
 func (a *Adapter[T, K]) Delete(ctx context.Context, id K) (int64, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, a.Timeout())
+	defer cancel()
+	if err := mgo.RunBeforeDelete[T](ctx, a.Hooks, id); err != nil {
+		return 0, err
+	}
+	return a.delete(ctx, id)
+}
+func (a *Adapter[T, K]) delete(ctx context.Context, id K) (int64, error) {
 	if a.ObjectId {
-		objId := fmt.Sprintf("%v", id)
-		objectId, err := primitive.ObjectIDFromHex(objId)
+		objectId, err := mgo.ToId(id, true)
 		if err != nil {
 			return 0, err
 		}
-		return mgo.DeleteOne(ctx, a.Collection, objectId)
+		res, err := mgo.DeleteOne(ctx, a.Collection, objectId)
+		return a.notFound(res, err)
+	}
+	res, err := mgo.DeleteOne(ctx, a.Collection, id)
+	return a.notFound(res, err)
+}
+
+// GetVersion returns the version of the model, which can be sent in ETag http header by mgo.ETag
+func (a *Adapter[T, K]) GetVersion(model *T) (interface{}, bool) {
+	if a.versionIndex < 0 || model == nil {
+		return nil, false
+	}
+	return reflect.ValueOf(model).Elem().Field(a.versionIndex).Interface(), true
+}
+func (a *Adapter[T, K]) insertOne(ctx context.Context, model *T) (*primitive.ObjectID, int64, error) {
+	if a.ReturnError {
+		return mgo.InsertOneWithError(ctx, a.Collection, model)
+	}
+	return mgo.InsertOne(ctx, a.Collection, model)
+}
+func (a *Adapter[T, K]) notFound(res int64, err error) (int64, error) {
+	if err == nil && res <= 0 && a.ReturnError {
+		return 0, mgo.ErrNotFound
+	}
+	return res, err
+}
+
+// conflict is called when the filter by id and version matches no document
+func (a *Adapter[T, K]) conflict(ctx context.Context, id interface{}) (int64, error) {
+	ok, err := mgo.Exist(ctx, a.Collection, id)
+	if !a.ReturnError {
+		if ok {
+			return -1, nil
+		}
+		return 0, nil
 	}
-	return mgo.DeleteOne(ctx, a.Collection, id)
+	if err != nil {
+		return 0, err
+	}
+	if ok {
+		return 0, mgo.ErrVersionConflict
+	}
+	return 0, mgo.ErrNotFound
+}
+
+// versionError is called after an upsert by id and version, which fails with a duplicate key on _id when the version does not match
+func (a *Adapter[T, K]) versionError(res int64, err error) (int64, error) {
+	if dup, ok := mgo.ToDuplicateKeyError(err); ok && a.ReturnError {
+		if dup.Index == "_id_" {
+			return 0, mgo.ErrVersionConflict
+		}
+		return 0, dup
+	}
+	return res, err
 }
 
 func setVersion(vo reflect.Value, versionIndex int) bool {
-	versionType := vo.Field(versionIndex).Type().String()
-	switch versionType {
-	case "int32":
-		vo.Field(versionIndex).Set(reflect.ValueOf(int32(1)))
-		return true
-	case "int":
-		vo.Field(versionIndex).Set(reflect.ValueOf(1))
-		return true
-	case "int64":
-		vo.Field(versionIndex).Set(reflect.ValueOf(int64(1)))
-		return true
-	default:
-		return false
+	v, ok := mgo.NewVersion(vo.Field(versionIndex).Type())
+	if ok {
+		vo.Field(versionIndex).Set(reflect.ValueOf(v))
 	}
+	return ok
 }
 func increaseVersion(vo reflect.Value, versionIndex int, curVer interface{}) bool {
-	versionType := vo.Field(versionIndex).Type().String()
-	switch versionType {
-	case "int32":
-		nextVer := curVer.(int32) + 1
-		vo.Field(versionIndex).Set(reflect.ValueOf(nextVer))
-		return true
-	case "int":
-		nextVer := curVer.(int) + 1
-		vo.Field(versionIndex).Set(reflect.ValueOf(nextVer))
-		return true
-	case "int64":
-		nextVer := curVer.(int64) + 1
-		vo.Field(versionIndex).Set(reflect.ValueOf(nextVer))
-		return true
-	default:
-		return false
+	v, ok := mgo.NextVersion(vo.Field(versionIndex).Type(), curVer)
+	if ok {
+		vo.Field(versionIndex).Set(reflect.ValueOf(v))
 	}
+	return ok
 }
-func increaseMapVersion(model map[string]interface{}, name string, currentVersion interface{}) bool {
-	if versionI32, ok := currentVersion.(int32); ok {
-		model[name] = versionI32 + 1
-		return true
-	} else if versionI, ok := currentVersion.(int); ok {
-		model[name] = versionI + 1
-		return true
-	} else if versionI64, ok := currentVersion.(int64); ok {
-		model[name] = versionI64 + 1
-		return true
-	} else {
-		return false
+
+// increaseMapVersion converts the current version of the map to the version type, and sets the next version to the map
+func increaseMapVersion(model map[string]interface{}, name string, versionType reflect.Type, currentVersion interface{}) (interface{}, bool) {
+	current, ok := mgo.ToVersion(versionType, currentVersion)
+	if !ok {
+		return nil, false
+	}
+	next, ok := mgo.NextVersion(versionType, current)
+	if !ok {
+		return nil, false
 	}
+	model[name] = next
+	return current, true
 }
@@ -0,0 +1,38 @@
+package aggregate
+
+// Accumulator is an output field of $group, such as Sum("total", "amount"), which outputs {total: {$sum: "$amount"}}
+type Accumulator struct {
+	Name     string
+	Operator string
+	Field    string
+}
+
+func Sum(name string, field string) Accumulator {
+	return Accumulator{Name: name, Operator: "$sum", Field: field}
+}
+
+// Count outputs the number of documents of each group
+func Count(name string) Accumulator {
+	return Accumulator{Name: name, Operator: "$sum"}
+}
+func Avg(name string, field string) Accumulator {
+	return Accumulator{Name: name, Operator: "$avg", Field: field}
+}
+func Min(name string, field string) Accumulator {
+	return Accumulator{Name: name, Operator: "$min", Field: field}
+}
+func Max(name string, field string) Accumulator {
+	return Accumulator{Name: name, Operator: "$max", Field: field}
+}
+func First(name string, field string) Accumulator {
+	return Accumulator{Name: name, Operator: "$first", Field: field}
+}
+func Last(name string, field string) Accumulator {
+	return Accumulator{Name: name, Operator: "$last", Field: field}
+}
+func Push(name string, field string) Accumulator {
+	return Accumulator{Name: name, Operator: "$push", Field: field}
+}
+func AddToSet(name string, field string) Accumulator {
+	return Accumulator{Name: name, Operator: "$addToSet", Field: field}
+}
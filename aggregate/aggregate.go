@@ -0,0 +1,237 @@
+package aggregate
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	mgo "github.com/core-go/mongo"
+	"github.com/core-go/mongo/repository"
+)
+
+// Aggregate builds the aggregation pipeline of the collection of T, and decodes the results into R.
+// The field names are json names of T, which are translated to bson names until a stage reshapes the documents, such as $group, $count, $facet or $project by Stage.
+// The names which are not in T, and all names after that stage, are kept.
+type Aggregate[T any, R any] struct {
+	Collection *mongo.Collection
+	Map        map[string]string
+	pipeline   mongo.Pipeline
+	reshaped   bool
+}
+
+// reshapes are the stages, after which the fields are not the fields of T
+var reshapes = map[string]bool{"$group": true, "$project": true, "$count": true, "$facet": true, "$replaceRoot": true, "$replaceWith": true,
+	"$bucket": true, "$bucketAuto": true, "$sortByCount": true}
+
+func NewAggregate[T any, R any](db *mongo.Database, collectionName string) *Aggregate[T, R] {
+	return NewAggregateWithCollection[T, R](db.Collection(collectionName))
+}
+func NewAggregateWithCollection[T any, R any](collection *mongo.Collection) *Aggregate[T, R] {
+	var t T
+	modelType := reflect.TypeOf(t)
+	if modelType.Kind() != reflect.Struct {
+		panic("T must be a struct")
+	}
+	return &Aggregate[T, R]{Collection: collection, Map: mgo.MakeBsonMap(modelType), pipeline: mongo.Pipeline{}}
+}
+
+// FromQuery seeds $match by the query builder of search, such as query.UseQuery.
+// It is not scoped by tenant or soft delete; a tenant-mode service should use FromRepository.
+func FromQuery[T any, R any, F any](collection *mongo.Collection, buildQuery func(F) (bson.D, bson.M), filter F) *Aggregate[T, R] {
+	a := NewAggregateWithCollection[T, R](collection)
+	query, _ := buildQuery(filter)
+	if len(query) > 0 {
+		a.Match(query)
+	}
+	return a
+}
+
+// FromRepository seeds $match by the conditions of soft delete and tenant of the repository, so that the pipeline reads the same documents as the repository
+func FromRepository[T any, R any, K any](ctx context.Context, repo *repository.Repository[T, K]) (*Aggregate[T, R], error) {
+	query, err := repo.ScopeFilter(ctx, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+	a := NewAggregateWithCollection[T, R](repo.Collection)
+	if len(query) > 0 {
+		a.Match(query)
+	}
+	return a, nil
+}
+
+// Sub returns an empty pipeline with the same field names, to build the pipelines of Facet and Lookup
+func (a *Aggregate[T, R]) Sub() *Aggregate[T, R] {
+	return &Aggregate[T, R]{Collection: a.Collection, Map: a.Map, pipeline: mongo.Pipeline{}, reshaped: a.reshaped}
+}
+func (a *Aggregate[T, R]) Pipeline() mongo.Pipeline {
+	return a.pipeline
+}
+
+// Stage adds a stage as it is
+func (a *Aggregate[T, R]) Stage(stage bson.D) *Aggregate[T, R] {
+	for _, e := range stage {
+		if reshapes[e.Key] {
+			a.reshaped = true
+		}
+	}
+	a.pipeline = append(a.pipeline, stage)
+	return a
+}
+
+// Match adds $match. The query is in bson names, as the output of query.Build.
+func (a *Aggregate[T, R]) Match(query bson.D) *Aggregate[T, R] {
+	return a.Stage(bson.D{{Key: "$match", Value: query}})
+}
+
+// Group adds $group. id is a json name, a slice of json names for a compound key, or nil to group all documents.
+func (a *Aggregate[T, R]) Group(id interface{}, accumulators ...Accumulator) *Aggregate[T, R] {
+	var groupId interface{}
+	switch v := id.(type) {
+	case nil:
+		groupId = nil
+	case string:
+		groupId = a.ref(v)
+	case []string:
+		d := bson.D{}
+		for _, name := range v {
+			d = append(d, bson.E{Key: outputName(name), Value: a.ref(name)})
+		}
+		groupId = d
+	default:
+		groupId = v
+	}
+	group := bson.D{{Key: "_id", Value: groupId}}
+	for _, acc := range accumulators {
+		var value interface{} = 1
+		if len(acc.Field) > 0 {
+			value = a.ref(acc.Field)
+		}
+		group = append(group, bson.E{Key: acc.Name, Value: bson.D{{Key: acc.Operator, Value: value}}})
+	}
+	return a.Stage(bson.D{{Key: "$group", Value: group}})
+}
+
+// Project adds $project, which includes the fields. The fields with "-" prefix are excluded. The names of the fields are not changed, so they are still translated after Project.
+func (a *Aggregate[T, R]) Project(fields ...string) *Aggregate[T, R] {
+	project := bson.D{}
+	for _, f := range fields {
+		if strings.HasPrefix(f, "-") {
+			project = append(project, bson.E{Key: a.field(f[1:]), Value: 0})
+		} else {
+			project = append(project, bson.E{Key: a.field(f), Value: 1})
+		}
+	}
+	a.pipeline = append(a.pipeline, bson.D{{Key: "$project", Value: project}})
+	return a
+}
+
+// Unwind adds $unwind. If preserveNullAndEmptyArrays is true, the documents without the array are kept.
+func (a *Aggregate[T, R]) Unwind(field string, preserveNullAndEmptyArrays ...bool) *Aggregate[T, R] {
+	if len(preserveNullAndEmptyArrays) > 0 && preserveNullAndEmptyArrays[0] {
+		return a.Stage(bson.D{{Key: "$unwind", Value: bson.D{{Key: "path", Value: a.ref(field)}, {Key: "preserveNullAndEmptyArrays", Value: true}}}})
+	}
+	return a.Stage(bson.D{{Key: "$unwind", Value: a.ref(field)}})
+}
+
+// Lookup adds $lookup. localField is a json name of T, foreignField is a bson name of the collection "from".
+func (a *Aggregate[T, R]) Lookup(from string, localField string, foreignField string, as string) *Aggregate[T, R] {
+	return a.Stage(bson.D{{Key: "$lookup", Value: bson.D{
+		{Key: "from", Value: from},
+		{Key: "localField", Value: a.field(localField)},
+		{Key: "foreignField", Value: foreignField},
+		{Key: "as", Value: as},
+	}}})
+}
+
+// Sort adds $sort. The fields with "-" prefix are sorted descending.
+func (a *Aggregate[T, R]) Sort(fields ...string) *Aggregate[T, R] {
+	d := bson.D{}
+	for _, f := range fields {
+		f = strings.TrimSpace(f)
+		if strings.HasPrefix(f, "-") {
+			d = append(d, bson.E{Key: a.field(f[1:]), Value: -1})
+		} else {
+			d = append(d, bson.E{Key: a.field(strings.TrimPrefix(f, "+")), Value: 1})
+		}
+	}
+	return a.Stage(bson.D{{Key: "$sort", Value: d}})
+}
+func (a *Aggregate[T, R]) Skip(skip int64) *Aggregate[T, R] {
+	return a.Stage(bson.D{{Key: "$skip", Value: skip}})
+}
+func (a *Aggregate[T, R]) Limit(limit int64) *Aggregate[T, R] {
+	return a.Stage(bson.D{{Key: "$limit", Value: limit}})
+}
+
+// Count adds $count, which outputs a document with the number of documents in the field name
+func (a *Aggregate[T, R]) Count(name string) *Aggregate[T, R] {
+	return a.Stage(bson.D{{Key: "$count", Value: name}})
+}
+
+// Facet adds $facet. The sub pipelines can be built by Sub.
+func (a *Aggregate[T, R]) Facet(facets map[string]*Aggregate[T, R]) *Aggregate[T, R] {
+	names := make([]string, 0, len(facets))
+	for name := range facets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	facet := bson.D{}
+	for _, name := range names {
+		facet = append(facet, bson.E{Key: name, Value: facets[name].pipeline})
+	}
+	return a.Stage(bson.D{{Key: "$facet", Value: facet}})
+}
+
+// All runs the pipeline, and decodes the results into R
+func (a *Aggregate[T, R]) All(ctx context.Context) ([]R, error) {
+	cursor, err := a.Collection.Aggregate(ctx, a.pipeline)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]R, 0)
+	if err = cursor.All(ctx, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// One runs the pipeline, and returns the first result, or nil if there is no result
+func (a *Aggregate[T, R]) One(ctx context.Context) (*R, error) {
+	cursor, err := a.Collection.Aggregate(ctx, a.pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	if !cursor.Next(ctx) {
+		return nil, cursor.Err()
+	}
+	var r R
+	if err = cursor.Decode(&r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+func (a *Aggregate[T, R]) field(name string) string {
+	if a.reshaped {
+		return name
+	}
+	return mgo.ToBsonName(a.Map, name)
+}
+func (a *Aggregate[T, R]) ref(name string) string {
+	if strings.HasPrefix(name, "$") {
+		return name
+	}
+	return "$" + a.field(name)
+}
+
+// outputName is the name of a part of a compound group key, "address.city" is output as "city"
+func outputName(name string) string {
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
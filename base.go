@@ -2,7 +2,7 @@ package mongo
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"reflect"
@@ -32,9 +32,12 @@ func FindField(modelType reflect.Type, bsonName string) (int, string, string) {
 }
 func Exist(ctx context.Context, collection *mongo.Collection, id interface{}) (bool, error) {
 	query := bson.M{"_id": id}
+	return ExistByFilter(ctx, collection, query)
+}
+func ExistByFilter(ctx context.Context, collection *mongo.Collection, query interface{}) (bool, error) {
 	x := collection.FindOne(ctx, query)
 	if x.Err() != nil {
-		if fmt.Sprint(x.Err()) == "mongo: no documents in result" {
+		if errors.Is(x.Err(), mongo.ErrNoDocuments) {
 			return false, nil
 		} else {
 			return false, x.Err()
@@ -2,11 +2,14 @@ package batch
 
 import (
 	"context"
+	"errors"
 	"reflect"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+
+	mgo "github.com/core-go/mongo"
 )
 
 func InsertMany[T any](ctx context.Context, collection *mongo.Collection, objs []T) ([]int, error) {
@@ -41,13 +44,22 @@ func UpdateMany[T any](ctx context.Context, collection *mongo.Collection, objs [
 		}
 		index = FindIdField(modelType)
 	}
+	return UpdateManyWithFilter[T](ctx, collection, objs, index, nil)
+}
+
+// UpdateManyWithFilter updates the models by id and the conditions of filter, such as the tenant id
+func UpdateManyWithFilter[T any](ctx context.Context, collection *mongo.Collection, objs []T, index int, filter bson.M) (*mongo.BulkWriteResult, error) {
+	le := len(objs)
+	if le == 0 {
+		return nil, nil
+	}
 	models := make([]mongo.WriteModel, 0)
 	for i := 0; i < le; i++ {
 		v := getValue(objs[i], index)
 		updateQuery := bson.M{
 			"$set": objs[i],
 		}
-		updateModel := mongo.NewUpdateOneModel().SetUpdate(updateQuery).SetFilter(bson.M{"_id": v})
+		updateModel := mongo.NewUpdateOneModel().SetUpdate(updateQuery).SetFilter(idFilter(v, filter))
 		models = append(models, updateModel)
 	}
 	res, err := collection.BulkWrite(ctx, models)
@@ -60,6 +72,11 @@ func getValue(model interface{}, index int) interface{} {
 
 // Patch
 func PatchMaps(ctx context.Context, collection *mongo.Collection, maps []map[string]interface{}, idName string) (*mongo.BulkWriteResult, error) {
+	return PatchMapsWithFilter(ctx, collection, maps, idName, nil)
+}
+
+// PatchMapsWithFilter patches the maps by id and the conditions of filter, such as the tenant id
+func PatchMapsWithFilter(ctx context.Context, collection *mongo.Collection, maps []map[string]interface{}, idName string, filter bson.M) (*mongo.BulkWriteResult, error) {
 	if idName == "" {
 		idName = "_id"
 	}
@@ -69,13 +86,35 @@ func PatchMaps(ctx context.Context, collection *mongo.Collection, maps []map[str
 		if v != nil {
 			updateModel := mongo.NewUpdateOneModel().SetUpdate(bson.M{
 				"$set": row,
-			}).SetFilter(bson.M{"_id": v})
+			}).SetFilter(idFilter(v, filter))
 			writeModels = append(writeModels, updateModel)
 		}
 	}
 	res, err := collection.BulkWrite(ctx, writeModels)
 	return res, err
 }
+
+// ApplyMany updates the documents of ids by the update operators, such as $inc, $push and $pull. The json names of updates are translated by objectMap, which is made by mgo.MakeBsonMap.
+// It is not scoped by tenant; use ApplyManyWithFilter with the filter of mgo.Tenant.FilterMap.
+func ApplyMany(ctx context.Context, collection *mongo.Collection, ids []interface{}, updates []*mgo.Update, objectMap map[string]string) (*mongo.BulkWriteResult, error) {
+	return ApplyManyWithFilter(ctx, collection, ids, updates, objectMap, nil)
+}
+
+// ApplyManyWithFilter updates the documents of ids and the conditions of filter, such as the tenant id, by the update operators
+func ApplyManyWithFilter(ctx context.Context, collection *mongo.Collection, ids []interface{}, updates []*mgo.Update, objectMap map[string]string, filter bson.M) (*mongo.BulkWriteResult, error) {
+	if len(ids) != len(updates) {
+		return nil, errors.New("ids and updates must have the same length")
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	writeModels := make([]mongo.WriteModel, 0)
+	for i, id := range ids {
+		updateModel := mongo.NewUpdateOneModel().SetUpdate(updates[i].Build(objectMap)).SetFilter(idFilter(id, filter))
+		writeModels = append(writeModels, updateModel)
+	}
+	return collection.BulkWrite(ctx, writeModels)
+}
 func UpsertMany[T any](ctx context.Context, collection *mongo.Collection, objs []T, opts ...int) (*mongo.BulkWriteResult, error) { //Patch
 	le := len(objs)
 	if le == 0 {
@@ -92,12 +131,21 @@ func UpsertMany[T any](ctx context.Context, collection *mongo.Collection, objs [
 		}
 		index = FindIdField(modelType)
 	}
+	return UpsertManyWithFilter[T](ctx, collection, objs, index, nil)
+}
+
+// UpsertManyWithFilter upserts the models by id and the conditions of filter, such as the tenant id
+func UpsertManyWithFilter[T any](ctx context.Context, collection *mongo.Collection, objs []T, index int, filter bson.M) (*mongo.BulkWriteResult, error) {
+	le := len(objs)
+	if le == 0 {
+		return nil, nil
+	}
 	models := make([]mongo.WriteModel, 0)
 
 	for i := 0; i < le; i++ {
 		id := getValue(objs[i], index)
-		if (reflect.TypeOf(id).String() == "string" && len(id.(string)) > 0) || !isNil(id) { // if exist
-			updateModel := mongo.NewReplaceOneModel().SetUpsert(true).SetReplacement(objs[i]).SetFilter(bson.M{"_id": id})
+		if !mgo.IsEmptyId(id) { // if exist
+			updateModel := mongo.NewReplaceOneModel().SetUpsert(true).SetReplacement(objs[i]).SetFilter(idFilter(id, filter))
 			models = append(models, updateModel)
 		} else {
 			insertModel := mongo.NewInsertOneModel().SetDocument(objs[i])
@@ -107,13 +155,10 @@ func UpsertMany[T any](ctx context.Context, collection *mongo.Collection, objs [
 	res, err := collection.BulkWrite(ctx, models)
 	return res, err
 }
-func isNil(i interface{}) bool {
-	if i == nil {
-		return true
-	}
-	switch reflect.TypeOf(i).Kind() {
-	case reflect.Ptr:
-		return reflect.ValueOf(i).IsNil()
+func idFilter(id interface{}, filter bson.M) bson.M {
+	m := bson.M{"_id": id}
+	for k, v := range filter {
+		m[k] = v
 	}
-	return false
+	return m
 }
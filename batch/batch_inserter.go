@@ -5,12 +5,17 @@ import (
 	"reflect"
 
 	"go.mongodb.org/mongo-driver/mongo"
+
+	mgo "github.com/core-go/mongo"
 )
 
 type BatchInserter[T any] struct {
 	collection *mongo.Collection
 	Map        func(*T)
+	Hooks      *mgo.Hooks[T]
 	retryAll   bool
+	Tenant     *mgo.Tenant
+	mgo.Options
 }
 
 func NewBatchInserterWithRetry[T any](db *mongo.Database, collectionName string, retryAll bool, opts ...func(*T)) *BatchInserter[T] {
@@ -24,13 +29,34 @@ func NewBatchInserterWithRetry[T any](db *mongo.Database, collectionName string,
 		mp = opts[0]
 	}
 	collection := db.Collection(collectionName)
-	return &BatchInserter[T]{collection: collection, Map: mp, retryAll: retryAll}
+	w := &BatchInserter[T]{collection: collection, Map: mp, retryAll: retryAll}
+	w.Options = mgo.NewOptions(&w.collection)
+	return w
 }
 func NewBatchInserter[T any](db *mongo.Database, collectionName string, opts ...func(*T)) *BatchInserter[T] {
 	return NewBatchInserterWithRetry[T](db, collectionName, false, opts...)
 }
 
+// SetTenant stamps the models with the tenant id, which is read from ctx.Value(tenantKey). Write fails with mgo.ErrNoTenant if there is no tenant in ctx.
+func (w *BatchInserter[T]) SetTenant(tenantKey string, tenantField string) {
+	var t T
+	w.Tenant = mgo.NewTenant(reflect.TypeOf(t), tenantKey, tenantField)
+}
 func (w *BatchInserter[T]) Write(ctx context.Context, models []T) ([]int, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, w.Timeout())
+	defer cancel()
+	if w.Tenant != nil {
+		for i := range models {
+			if err := w.Tenant.Stamp(ctx, &models[i]); err != nil {
+				return []int{i}, err
+			}
+		}
+	}
+	for i := range models {
+		if err := mgo.RunBeforeCreate(ctx, w.Hooks, &models[i]); err != nil {
+			return []int{i}, err
+		}
+	}
 	if w.Map != nil {
 		l := len(models)
 		for i := 0; i < l; i++ {
@@ -46,5 +72,12 @@ func (w *BatchInserter[T]) Write(ctx context.Context, models []T) ([]int, error)
 		}
 		return failIndices, err
 	}
+	if err == nil {
+		for i := range models {
+			if er1 := mgo.RunAfterCreate(ctx, w.Hooks, &models[i]); er1 != nil {
+				return []int{i}, er1
+			}
+		}
+	}
 	return fails, err
 }
@@ -3,12 +3,17 @@ package batch
 import (
 	"context"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+
+	mgo "github.com/core-go/mongo"
 )
 
 type BatchPatcher struct {
 	collection *mongo.Collection
 	IdName     string
+	Tenant     *mgo.Tenant
+	mgo.Options
 }
 
 func NewBatchPatcherWithId(database *mongo.Database, collectionName string, fieldName string) *BatchPatcher {
@@ -21,12 +26,34 @@ func NewBatchPatcher(database *mongo.Database, collectionName string) *BatchPatc
 
 func CreateMongoBatchPatcherIdName(database *mongo.Database, collectionName string, fieldName string) *BatchPatcher {
 	collection := database.Collection(collectionName)
-	return &BatchPatcher{collection, fieldName}
+	w := &BatchPatcher{collection: collection, IdName: fieldName}
+	w.Options = mgo.NewOptions(&w.collection)
+	return w
+}
+
+// SetTenant stamps the maps with the tenant id, which is read from ctx.Value(tenantKey), and patches only the documents of this tenant.
+// tenantField is the bson name, because the maps have bson names. Write fails with mgo.ErrNoTenant if there is no tenant in ctx.
+func (w *BatchPatcher) SetTenant(tenantKey string, tenantField string) {
+	w.Tenant = &mgo.Tenant{Key: tenantKey, Index: -1, Json: tenantField, Bson: tenantField}
 }
 
 func (w *BatchPatcher) Write(ctx context.Context, models []map[string]interface{}) ([]int, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, w.Timeout())
+	defer cancel()
 	failIndices := make([]int, 0)
-	_, err := PatchMaps(ctx, w.collection, models, w.IdName)
+	var filter bson.M
+	if w.Tenant != nil {
+		var err error
+		if filter, err = w.Tenant.FilterMap(ctx, nil); err != nil {
+			return []int{0}, err
+		}
+		for i := range models {
+			if err = w.Tenant.StampMap(ctx, models[i]); err != nil {
+				return []int{i}, err
+			}
+		}
+	}
+	_, err := PatchMapsWithFilter(ctx, w.collection, models, w.IdName, filter)
 
 	if err == nil {
 		return failIndices, err
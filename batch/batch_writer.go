@@ -2,15 +2,21 @@ package batch
 
 import (
 	"context"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"reflect"
+
+	mgo "github.com/core-go/mongo"
 )
 
 type BatchWriter[T any] struct {
 	collection *mongo.Collection
 	Idx        int
 	Map        func(*T)
+	Hooks      *mgo.Hooks[T]
 	retryAll   bool
+	Tenant     *mgo.Tenant
+	mgo.Options
 }
 
 func NewBatchWriterWithRetry[T any](db *mongo.Database, collectionName string, retryAll bool, opts ...func(*T)) *BatchWriter[T] {
@@ -28,23 +34,66 @@ func NewBatchWriterWithRetry[T any](db *mongo.Database, collectionName string, r
 		mp = opts[0]
 	}
 	collection := db.Collection(collectionName)
-	return &BatchWriter[T]{collection, idx, mp, retryAll}
+	w := &BatchWriter[T]{collection: collection, Idx: idx, Map: mp, retryAll: retryAll}
+	w.Options = mgo.NewOptions(&w.collection)
+	return w
 }
 func NewBatchWriter[T any](db *mongo.Database, collectionName string, opts ...func(*T)) *BatchWriter[T] {
 	return NewBatchWriterWithRetry[T](db, collectionName, false, opts...)
 }
+
+// SetTenant stamps the models with the tenant id, which is read from ctx.Value(tenantKey). Write fails with mgo.ErrNoTenant if there is no tenant in ctx.
+func (w *BatchWriter[T]) SetTenant(tenantKey string, tenantField string) {
+	var t T
+	w.Tenant = mgo.NewTenant(reflect.TypeOf(t), tenantKey, tenantField)
+}
 func (w *BatchWriter[T]) Write(ctx context.Context, models []T) ([]int, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, w.Timeout())
+	defer cancel()
 	failIndices := make([]int, 0)
 	var err error
+	var filter bson.M
+	if w.Tenant != nil {
+		if filter, err = w.Tenant.FilterMap(ctx, nil); err != nil {
+			return []int{0}, err
+		}
+		for i := range models {
+			if err = w.Tenant.Stamp(ctx, &models[i]); err != nil {
+				return []int{i}, err
+			}
+		}
+	}
+	creates := make([]bool, len(models))
+	for i := range models {
+		creates[i] = mgo.IsEmptyId(getValue(models[i], w.Idx))
+		if creates[i] {
+			err = mgo.RunBeforeCreate(ctx, w.Hooks, &models[i])
+		} else {
+			err = mgo.RunBeforeUpdate(ctx, w.Hooks, &models[i])
+		}
+		if err != nil {
+			return []int{i}, err
+		}
+	}
 	if w.Map != nil {
 		l := len(models)
 		for i := 0; i < l; i++ {
 			w.Map(&models[i])
 		}
 	}
-	_, err = UpsertMany[T](ctx, w.collection, models, w.Idx)
+	_, err = UpsertManyWithFilter[T](ctx, w.collection, models, w.Idx, filter)
 
 	if err == nil {
+		for i := range models {
+			if creates[i] {
+				err = mgo.RunAfterCreate(ctx, w.Hooks, &models[i])
+			} else {
+				err = mgo.RunAfterUpdate(ctx, w.Hooks, &models[i])
+			}
+			if err != nil {
+				return []int{i}, err
+			}
+		}
 		return failIndices, err
 	}
 
@@ -4,6 +4,8 @@ import (
 	"context"
 	"go.mongodb.org/mongo-driver/mongo"
 	"reflect"
+
+	mgo "github.com/core-go/mongo"
 )
 
 type StreamInserter[T any] struct {
@@ -12,7 +14,11 @@ type StreamInserter[T any] struct {
 	batchSize  int
 	batch      []interface{}
 	Map        func(T)
+	Hooks      *mgo.Hooks[T]
+	Tenant     *mgo.Tenant
 	isPointer  bool
+	models     []T
+	mgo.Options
 }
 
 func NewStreamInserter[T any](db *mongo.Database, collectionName string, batchSize int, opts ...func(T)) *StreamInserter[T] {
@@ -33,9 +39,27 @@ func NewStreamInserter[T any](db *mongo.Database, collectionName string, batchSi
 	}
 	collection := db.Collection(collectionName)
 	batch := make([]interface{}, 0)
-	return &StreamInserter[T]{collection, idx, batchSize, batch, mp, isPointer}
+	w := &StreamInserter[T]{collection: collection, Idx: idx, batchSize: batchSize, batch: batch, Map: mp, isPointer: isPointer}
+	w.Options = mgo.NewOptions(&w.collection)
+	return w
 }
+
+// SetTenant stamps the models with the tenant id, which is read from ctx.Value(tenantKey). Write fails with mgo.ErrNoTenant if there is no tenant in ctx.
+func (w *StreamInserter[T]) SetTenant(tenantKey string, tenantField string) {
+	var t T
+	w.Tenant = mgo.NewTenant(reflect.TypeOf(t), tenantKey, tenantField)
+}
+
+// Write calls BeforeCreate, and buffers the model. AfterCreate is called by Flush, after the models are written.
 func (w *StreamInserter[T]) Write(ctx context.Context, model T) error {
+	if w.Tenant != nil {
+		if err := w.Tenant.Stamp(ctx, &model); err != nil {
+			return err
+		}
+	}
+	if err := mgo.RunBeforeCreate(ctx, w.Hooks, &model); err != nil {
+		return err
+	}
 	if w.Map != nil {
 		w.Map(model)
 	}
@@ -44,6 +68,7 @@ func (w *StreamInserter[T]) Write(ctx context.Context, model T) error {
 		vo = reflect.Indirect(vo)
 	}
 	w.batch = append(w.batch, vo.Interface())
+	w.models = append(w.models, model)
 	if len(w.batch) >= w.batchSize {
 		return w.Flush(ctx)
 	}
@@ -53,7 +78,19 @@ func (w *StreamInserter[T]) Flush(ctx context.Context) error {
 	if len(w.batch) == 0 {
 		return nil
 	}
+	ctx, cancel := mgo.WithTimeout(ctx, w.Timeout())
+	defer cancel()
 	_, err := InsertMany[interface{}](ctx, w.collection, w.batch)
+	models := w.models
 	w.batch = make([]interface{}, 0)
-	return err
+	w.models = nil
+	if err != nil {
+		return err
+	}
+	for i := range models {
+		if err = mgo.RunAfterCreate(ctx, w.Hooks, &models[i]); err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -2,8 +2,11 @@ package batch
 
 import (
 	"context"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"reflect"
+
+	mgo "github.com/core-go/mongo"
 )
 
 type StreamUpdater[T any] struct {
@@ -12,7 +15,11 @@ type StreamUpdater[T any] struct {
 	batchSize  int
 	batch      []interface{}
 	Map        func(T)
+	Hooks      *mgo.Hooks[T]
+	Tenant     *mgo.Tenant
 	isPointer  bool
+	models     []T
+	mgo.Options
 }
 
 func NewStreamUpdater[T any](db *mongo.Database, collectionName string, batchSize int, opts ...func(T)) *StreamUpdater[T] {
@@ -33,9 +40,27 @@ func NewStreamUpdater[T any](db *mongo.Database, collectionName string, batchSiz
 	}
 	collection := db.Collection(collectionName)
 	batch := make([]interface{}, 0)
-	return &StreamUpdater[T]{collection, idx, batchSize, batch, mp, isPointer}
+	w := &StreamUpdater[T]{collection: collection, Idx: idx, batchSize: batchSize, batch: batch, Map: mp, isPointer: isPointer}
+	w.Options = mgo.NewOptions(&w.collection)
+	return w
+}
+
+// SetTenant stamps the models with the tenant id, which is read from ctx.Value(tenantKey). Write fails with mgo.ErrNoTenant if there is no tenant in ctx.
+func (w *StreamUpdater[T]) SetTenant(tenantKey string, tenantField string) {
+	var t T
+	w.Tenant = mgo.NewTenant(reflect.TypeOf(t), tenantKey, tenantField)
 }
+
+// Write calls BeforeUpdate, and buffers the model. AfterUpdate is called by Flush, after the models are written.
 func (w *StreamUpdater[T]) Write(ctx context.Context, model T) error {
+	if w.Tenant != nil {
+		if err := w.Tenant.Stamp(ctx, &model); err != nil {
+			return err
+		}
+	}
+	if err := mgo.RunBeforeUpdate(ctx, w.Hooks, &model); err != nil {
+		return err
+	}
 	if w.Map != nil {
 		w.Map(model)
 	}
@@ -44,6 +69,7 @@ func (w *StreamUpdater[T]) Write(ctx context.Context, model T) error {
 		vo = reflect.Indirect(vo)
 	}
 	w.batch = append(w.batch, vo.Interface())
+	w.models = append(w.models, model)
 	if len(w.batch) >= w.batchSize {
 		return w.Flush(ctx)
 	}
@@ -53,7 +79,26 @@ func (w *StreamUpdater[T]) Flush(ctx context.Context) error {
 	if len(w.batch) == 0 {
 		return nil
 	}
-	_, err := UpdateMany[interface{}](ctx, w.collection, w.batch, w.Idx)
+	ctx, cancel := mgo.WithTimeout(ctx, w.Timeout())
+	defer cancel()
+	var filter bson.M
+	if w.Tenant != nil {
+		var err error
+		if filter, err = w.Tenant.FilterMap(ctx, nil); err != nil {
+			return err
+		}
+	}
+	_, err := UpdateManyWithFilter[interface{}](ctx, w.collection, w.batch, w.Idx, filter)
+	models := w.models
 	w.batch = make([]interface{}, 0)
-	return err
+	w.models = nil
+	if err != nil {
+		return err
+	}
+	for i := range models {
+		if err = mgo.RunAfterUpdate(ctx, w.Hooks, &models[i]); err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -2,8 +2,11 @@ package batch
 
 import (
 	"context"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"reflect"
+
+	mgo "github.com/core-go/mongo"
 )
 
 type StreamWriter[T any] struct {
@@ -12,7 +15,12 @@ type StreamWriter[T any] struct {
 	batchSize  int
 	batch      []interface{}
 	Map        func(T)
+	Hooks      *mgo.Hooks[T]
+	Tenant     *mgo.Tenant
 	isPointer  bool
+	models     []T
+	creates    []bool
+	mgo.Options
 }
 
 func NewStreamWriter[T any](db *mongo.Database, collectionName string, batchSize int, opts ...func(T)) *StreamWriter[T] {
@@ -33,27 +41,82 @@ func NewStreamWriter[T any](db *mongo.Database, collectionName string, batchSize
 	}
 	collection := db.Collection(collectionName)
 	batch := make([]interface{}, 0)
-	return &StreamWriter[T]{collection, idx, batchSize, batch, mp, isPointer}
+	w := &StreamWriter[T]{collection: collection, Idx: idx, batchSize: batchSize, batch: batch, Map: mp, isPointer: isPointer}
+	w.Options = mgo.NewOptions(&w.collection)
+	return w
+}
+
+// SetTenant stamps the models with the tenant id, which is read from ctx.Value(tenantKey). Write fails with mgo.ErrNoTenant if there is no tenant in ctx.
+func (w *StreamWriter[T]) SetTenant(tenantKey string, tenantField string) {
+	var t T
+	w.Tenant = mgo.NewTenant(reflect.TypeOf(t), tenantKey, tenantField)
 }
+
+// Write calls BeforeCreate or BeforeUpdate, and buffers the model. AfterCreate and AfterUpdate are called by Flush, after the models are written.
 func (w *StreamWriter[T]) Write(ctx context.Context, model T) error {
+	var err error
+	if w.Tenant != nil {
+		if err = w.Tenant.Stamp(ctx, &model); err != nil {
+			return err
+		}
+	}
+	create := mgo.IsEmptyId(w.value(model).Field(w.Idx).Interface())
+	if create {
+		err = mgo.RunBeforeCreate(ctx, w.Hooks, &model)
+	} else {
+		err = mgo.RunBeforeUpdate(ctx, w.Hooks, &model)
+	}
+	if err != nil {
+		return err
+	}
 	if w.Map != nil {
 		w.Map(model)
 	}
-	vo := reflect.ValueOf(model)
-	if w.isPointer {
-		vo = reflect.Indirect(vo)
-	}
-	w.batch = append(w.batch, vo.Interface())
+	w.batch = append(w.batch, w.value(model).Interface())
+	w.models = append(w.models, model)
+	w.creates = append(w.creates, create)
 	if len(w.batch) >= w.batchSize {
 		return w.Flush(ctx)
 	}
 	return nil
 }
+func (w *StreamWriter[T]) value(model T) reflect.Value {
+	vo := reflect.ValueOf(model)
+	if w.isPointer {
+		vo = reflect.Indirect(vo)
+	}
+	return vo
+}
 func (w *StreamWriter[T]) Flush(ctx context.Context) error {
 	if len(w.batch) == 0 {
 		return nil
 	}
-	_, err := UpsertMany[interface{}](ctx, w.collection, w.batch, w.Idx)
+	ctx, cancel := mgo.WithTimeout(ctx, w.Timeout())
+	defer cancel()
+	var filter bson.M
+	if w.Tenant != nil {
+		var err error
+		if filter, err = w.Tenant.FilterMap(ctx, nil); err != nil {
+			return err
+		}
+	}
+	_, err := UpsertManyWithFilter[interface{}](ctx, w.collection, w.batch, w.Idx, filter)
+	models, creates := w.models, w.creates
 	w.batch = make([]interface{}, 0)
-	return err
+	w.models = nil
+	w.creates = nil
+	if err != nil {
+		return err
+	}
+	for i := range models {
+		if creates[i] {
+			err = mgo.RunAfterCreate(ctx, w.Hooks, &models[i])
+		} else {
+			err = mgo.RunAfterUpdate(ctx, w.Hooks, &models[i])
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
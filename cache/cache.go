@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Cache is the store of the cached models, which can be replaced by a shared cache, such as redis
+type Cache interface {
+	Get(key string) (interface{}, bool)
+	Set(key string, value interface{})
+	Delete(key string)
+	Clear()
+}
+
+type entry struct {
+	key     string
+	value   interface{}
+	expires time.Time
+}
+
+// LRU is an in-process cache, which evicts the least recently used entry when it is full, and the entries older than ttl
+type LRU struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	items    map[string]*list.Element
+	order    *list.List
+}
+
+// NewLRU creates a LRU cache. If capacity <= 0, the cache has no limit. If ttl <= 0, the entries do not expire.
+func NewLRU(capacity int, ttl time.Duration) *LRU {
+	return &LRU{capacity: capacity, ttl: ttl, items: make(map[string]*list.Element), order: list.New()}
+}
+func (c *LRU) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	en := e.Value.(*entry)
+	if c.ttl > 0 && time.Now().After(en.expires) {
+		c.remove(e)
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	return en.value, true
+}
+func (c *LRU) Set(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var expires time.Time
+	if c.ttl > 0 {
+		expires = time.Now().Add(c.ttl)
+	}
+	if e, ok := c.items[key]; ok {
+		en := e.Value.(*entry)
+		en.value = value
+		en.expires = expires
+		c.order.MoveToFront(e)
+		return
+	}
+	c.items[key] = c.order.PushFront(&entry{key: key, value: value, expires: expires})
+	if c.capacity > 0 && c.order.Len() > c.capacity {
+		c.remove(c.order.Back())
+	}
+}
+func (c *LRU) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.remove(e)
+	}
+}
+func (c *LRU) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items = make(map[string]*list.Element)
+	c.order.Init()
+}
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+func (c *LRU) remove(e *list.Element) {
+	c.order.Remove(e)
+	delete(c.items, e.Value.(*entry).key)
+}
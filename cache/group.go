@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var errPanic = errors.New("the shared load panicked")
+
+type call struct {
+	done chan struct{}
+	val  interface{}
+	err  error
+}
+
+// group runs only one call of fn for the same key at a time. The concurrent callers of the same key wait for it, and get the same result,
+// or the error of their own context if it is cancelled while they wait. If fn panics, the panic goes to the caller which runs fn, and the others get an error.
+type group struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+func (g *group) do(ctx context.Context, key string, fn func() (interface{}, error)) (interface{}, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*call)
+	}
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		select {
+		case <-c.done:
+			return c.val, c.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	c := &call{done: make(chan struct{}), err: errPanic}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(c.done)
+	}()
+	c.val, c.err = fn()
+	return c.val, c.err
+}
+
+// detached keeps the values of the context, such as the tenant, without its deadline and cancellation,
+// so that the load shared by the concurrent callers does not fail when the caller which runs it is cancelled
+type detached struct {
+	ctx context.Context
+}
+
+func (d detached) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+func (d detached) Done() <-chan struct{} {
+	return nil
+}
+func (d detached) Err() error {
+	return nil
+}
+func (d detached) Value(key interface{}) interface{} {
+	return d.ctx.Value(key)
+}
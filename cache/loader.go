@@ -0,0 +1,216 @@
+package cache
+
+import (
+	"context"
+	"reflect"
+	"sync/atomic"
+
+	mgo "github.com/core-go/mongo"
+)
+
+type Loader[T any, K any] interface {
+	All(ctx context.Context) ([]T, error)
+	Load(ctx context.Context, id K) (*T, error)
+	Exist(ctx context.Context, id K) (bool, error)
+}
+
+type Stats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// CachedLoader loads the models from the cache first, and from the loader, such as query.Loader, when they are not in the cache.
+// The concurrent loads of the same id are sent to the loader once, with the values of the context of the first call, but without its deadline,
+// so the loader should have a timeout, such as by SetTimeout. The models which are not found are not cached.
+type CachedLoader[T any, K any] struct {
+	// the counters are first, to be 64-bit aligned for atomic operations
+	generation uint64
+	hits       uint64
+	misses     uint64
+	Loader     Loader[T, K]
+	Cache      Cache
+	group      group
+	tenantKey  string
+}
+
+// tenantModel is the cached model of a tenant
+type tenantModel[T any] struct {
+	tenant string
+	model  *T
+}
+
+// NewLoader creates the CachedLoader. If the loader has a tenant, such as query.Loader with Tenant, the cache is scoped by the tenant, see SetTenant.
+func NewLoader[T any, K any](loader Loader[T, K], cache Cache) *CachedLoader[T, K] {
+	c := &CachedLoader[T, K]{Loader: loader, Cache: cache}
+	if t, ok := loader.(interface{ TenantKey() string }); ok {
+		c.tenantKey = t.TenantKey()
+	}
+	return c
+}
+
+// SetTenant keeps the tenant id of ctx.Value(tenantKey) with the cached models, so that a tenant cannot load or check the cached models of another tenant
+func (c *CachedLoader[T, K]) SetTenant(tenantKey string) {
+	c.tenantKey = tenantKey
+}
+
+// All is not cached
+func (c *CachedLoader[T, K]) All(ctx context.Context) ([]T, error) {
+	return c.Loader.All(ctx)
+}
+
+// Load returns a deep copy of the cached model, so that the caller can change it
+func (c *CachedLoader[T, K]) Load(ctx context.Context, id K) (*T, error) {
+	tenant, err := c.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key := mgo.ToKey(id)
+	if model, ok := c.get(key, tenant); ok {
+		atomic.AddUint64(&c.hits, 1)
+		return clone(model), nil
+	}
+	atomic.AddUint64(&c.misses, 1)
+	v, err := c.group.do(ctx, tenant+"\x00"+key, func() (interface{}, error) {
+		generation := atomic.LoadUint64(&c.generation)
+		model, err := c.Loader.Load(detached{ctx: ctx}, id)
+		// the model is not cached if it is invalidated while loading, because it may be stale
+		if err == nil && model != nil && generation == atomic.LoadUint64(&c.generation) {
+			c.set(key, tenant, clone(model))
+		}
+		return model, err
+	})
+	if err != nil || v == nil {
+		return nil, err
+	}
+	return clone(v.(*T)), nil
+}
+func (c *CachedLoader[T, K]) Exist(ctx context.Context, id K) (bool, error) {
+	tenant, err := c.tenant(ctx)
+	if err != nil {
+		return false, err
+	}
+	if _, ok := c.get(mgo.ToKey(id), tenant); ok {
+		atomic.AddUint64(&c.hits, 1)
+		return true, nil
+	}
+	atomic.AddUint64(&c.misses, 1)
+	return c.Loader.Exist(ctx, id)
+}
+func (c *CachedLoader[T, K]) tenant(ctx context.Context) (string, error) {
+	if len(c.tenantKey) == 0 {
+		return "", nil
+	}
+	tenant, err := mgo.GetTenant(ctx, c.tenantKey)
+	if err != nil {
+		return "", err
+	}
+	return mgo.ToKey(tenant), nil
+}
+
+// get returns the cached model, which is a miss if it is of another tenant. The key is only the id, because the ids are unique in the collection of all tenants.
+func (c *CachedLoader[T, K]) get(key string, tenant string) (*T, bool) {
+	v, ok := c.Cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+	if len(c.tenantKey) == 0 {
+		model, ok := v.(*T)
+		return model, ok
+	}
+	m, ok := v.(tenantModel[T])
+	if !ok || m.tenant != tenant {
+		return nil, false
+	}
+	return m.model, true
+}
+func (c *CachedLoader[T, K]) set(key string, tenant string, model *T) {
+	if len(c.tenantKey) == 0 {
+		c.Cache.Set(key, model)
+	} else {
+		c.Cache.Set(key, tenantModel[T]{tenant: tenant, model: model})
+	}
+}
+
+// Invalidate removes the models of the ids from the cache, such as when they are changed by another service
+func (c *CachedLoader[T, K]) Invalidate(ids ...K) {
+	atomic.AddUint64(&c.generation, 1)
+	for _, id := range ids {
+		c.Cache.Delete(mgo.ToKey(id))
+	}
+}
+func (c *CachedLoader[T, K]) InvalidateAll() {
+	atomic.AddUint64(&c.generation, 1)
+	c.Cache.Clear()
+}
+func (c *CachedLoader[T, K]) invalidateKey(key string) {
+	atomic.AddUint64(&c.generation, 1)
+	c.Cache.Delete(key)
+}
+func (c *CachedLoader[T, K]) Stats() Stats {
+	return Stats{Hits: atomic.LoadUint64(&c.hits), Misses: atomic.LoadUint64(&c.misses)}
+}
+func clone[T any](model *T) *T {
+	if model == nil {
+		return nil
+	}
+	return deepCopy(reflect.ValueOf(model)).Interface().(*T)
+}
+
+// deepCopy copies the pointers, slices, maps and interfaces of the exported fields. The unexported fields are copied as they are.
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		p := reflect.New(v.Type().Elem())
+		p.Elem().Set(deepCopy(v.Elem()))
+		return p
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		s := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			reflect.Copy(s, v)
+			return s
+		}
+		for i := 0; i < v.Len(); i++ {
+			s.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return s
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		m := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m.SetMapIndex(iter.Key(), deepCopy(iter.Value()))
+		}
+		return m
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		x := reflect.New(v.Type()).Elem()
+		x.Set(deepCopy(v.Elem()))
+		return x
+	case reflect.Array, reflect.Struct:
+		x := reflect.New(v.Type()).Elem()
+		x.Set(v)
+		if v.Kind() == reflect.Array {
+			for i := 0; i < v.Len(); i++ {
+				x.Index(i).Set(deepCopy(v.Index(i)))
+			}
+			return x
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if x.Field(i).CanSet() {
+				x.Field(i).Set(deepCopy(v.Field(i)))
+			}
+		}
+		return x
+	}
+	return v
+}
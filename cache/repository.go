@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"context"
+	"reflect"
+
+	mgo "github.com/core-go/mongo"
+)
+
+type Repository[T any, K any] interface {
+	Loader[T, K]
+	Create(ctx context.Context, model *T) (int64, error)
+	Update(ctx context.Context, model *T) (int64, error)
+	Patch(ctx context.Context, model map[string]interface{}) (int64, error)
+	Save(ctx context.Context, model *T) (int64, error)
+	Delete(ctx context.Context, id K) (int64, error)
+}
+
+// CachedRepository is the CachedLoader of a repository, such as repository.Repository. Update, Patch, Save and Delete invalidate the models of their ids.
+// If T has no _id field, or the id is not in the map of Patch, the whole cache is invalidated.
+type CachedRepository[T any, K any] struct {
+	*CachedLoader[T, K]
+	Repository Repository[T, K]
+	idIndex    int
+	idJson     string
+}
+
+func NewRepository[T any, K any](repository Repository[T, K], cache Cache) *CachedRepository[T, K] {
+	var t T
+	modelType := reflect.TypeOf(t)
+	if modelType.Kind() != reflect.Struct {
+		panic("T must be a struct")
+	}
+	idIndex, _, idJson := mgo.FindIdField(modelType)
+	return &CachedRepository[T, K]{CachedLoader: NewLoader[T, K](repository, cache), Repository: repository, idIndex: idIndex, idJson: idJson}
+}
+
+// Create does not invalidate, because the models which are not found are not cached
+func (c *CachedRepository[T, K]) Create(ctx context.Context, model *T) (int64, error) {
+	return c.Repository.Create(ctx, model)
+}
+func (c *CachedRepository[T, K]) Update(ctx context.Context, model *T) (int64, error) {
+	defer c.invalidateModel(model)
+	return c.Repository.Update(ctx, model)
+}
+func (c *CachedRepository[T, K]) Patch(ctx context.Context, model map[string]interface{}) (int64, error) {
+	id, ok := model[c.idJson]
+	if ok && id != nil {
+		key := mgo.ToKey(id)
+		defer c.invalidateKey(key)
+	} else {
+		defer c.InvalidateAll()
+	}
+	return c.Repository.Patch(ctx, model)
+}
+func (c *CachedRepository[T, K]) Save(ctx context.Context, model *T) (int64, error) {
+	defer c.invalidateModel(model)
+	return c.Repository.Save(ctx, model)
+}
+func (c *CachedRepository[T, K]) Delete(ctx context.Context, id K) (int64, error) {
+	defer c.Invalidate(id)
+	return c.Repository.Delete(ctx, id)
+}
+func (c *CachedRepository[T, K]) invalidateModel(model *T) {
+	if c.idIndex < 0 || model == nil {
+		c.InvalidateAll()
+		return
+	}
+	c.invalidateKey(mgo.ToKey(reflect.ValueOf(model).Elem().Field(c.idIndex).Interface()))
+}
@@ -0,0 +1,52 @@
+package client
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// CollectionConfig is the config of a collection. WTimeout, MaxStaleness and Timeout are in milliseconds.
+// Timeout is the default timeout of each operation, which is applied when the context has no deadline.
+type CollectionConfig struct {
+	ReadPreference string `yaml:"read_preference" mapstructure:"read_preference" json:"readPreference,omitempty" gorm:"column:readpreference" bson:"readPreference,omitempty" dynamodbav:"readPreference,omitempty" firestore:"readPreference,omitempty"`
+	MaxStaleness   int64  `yaml:"max_staleness" mapstructure:"max_staleness" json:"maxStaleness,omitempty" gorm:"column:maxstaleness" bson:"maxStaleness,omitempty" dynamodbav:"maxStaleness,omitempty" firestore:"maxStaleness,omitempty"`
+	ReadConcern    string `yaml:"read_concern" mapstructure:"read_concern" json:"readConcern,omitempty" gorm:"column:readconcern" bson:"readConcern,omitempty" dynamodbav:"readConcern,omitempty" firestore:"readConcern,omitempty"`
+	W              string `yaml:"w" mapstructure:"w" json:"w,omitempty" gorm:"column:w" bson:"w,omitempty" dynamodbav:"w,omitempty" firestore:"w,omitempty"`
+	Journal        *bool  `yaml:"journal" mapstructure:"journal" json:"journal,omitempty" gorm:"column:journal" bson:"journal,omitempty" dynamodbav:"journal,omitempty" firestore:"journal,omitempty"`
+	WTimeout       int64  `yaml:"wtimeout" mapstructure:"wtimeout" json:"wtimeout,omitempty" gorm:"column:wtimeout" bson:"wtimeout,omitempty" dynamodbav:"wtimeout,omitempty" firestore:"wtimeout,omitempty"`
+	Timeout        int64  `yaml:"timeout" mapstructure:"timeout" json:"timeout,omitempty" gorm:"column:timeout" bson:"timeout,omitempty" dynamodbav:"timeout,omitempty" firestore:"timeout,omitempty"`
+}
+
+// NewCollectionOptions returns the read preference, read concern and write concern of the config, such as secondaryPreferred for search, and majority for write
+func NewCollectionOptions(c CollectionConfig) (*options.CollectionOptions, error) {
+	opts := options.Collection()
+	if len(c.ReadPreference) > 0 {
+		mode, err := readpref.ModeFromString(c.ReadPreference)
+		if err != nil {
+			return nil, err
+		}
+		var prefOpts []readpref.Option
+		if c.MaxStaleness > 0 {
+			prefOpts = append(prefOpts, readpref.WithMaxStaleness(time.Duration(c.MaxStaleness)*time.Millisecond))
+		}
+		pref, err := readpref.New(mode, prefOpts...)
+		if err != nil {
+			return nil, err
+		}
+		opts.SetReadPreference(pref)
+	}
+	if rc := NewReadConcern(c.ReadConcern); rc != nil {
+		opts.SetReadConcern(rc)
+	}
+	if wc := NewWriteConcern(c.W, c.Journal, time.Duration(c.WTimeout)*time.Millisecond); wc != nil {
+		opts.SetWriteConcern(wc)
+	}
+	return opts, nil
+}
+
+// GetTimeout returns the default timeout of each operation of the config
+func GetTimeout(c CollectionConfig) time.Duration {
+	return time.Duration(c.Timeout) * time.Millisecond
+}
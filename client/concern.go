@@ -0,0 +1,32 @@
+package client
+
+import (
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+func NewReadConcern(level string) *readconcern.ReadConcern {
+	if len(level) == 0 {
+		return nil
+	}
+	return &readconcern.ReadConcern{Level: level}
+}
+
+// w can be "majority", a tag set name or a number of nodes, such as "1"
+func NewWriteConcern(w string, journal *bool, wtimeout time.Duration) *writeconcern.WriteConcern {
+	if len(w) == 0 && journal == nil && wtimeout <= 0 {
+		return nil
+	}
+	wc := &writeconcern.WriteConcern{Journal: journal, WTimeout: wtimeout}
+	if len(w) > 0 {
+		if n, err := strconv.Atoi(w); err == nil {
+			wc.W = n
+		} else {
+			wc.W = w
+		}
+	}
+	return wc
+}
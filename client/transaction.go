@@ -0,0 +1,78 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	TransientTransactionError      = "TransientTransactionError"
+	UnknownTransactionCommitResult = "UnknownTransactionCommitResult"
+)
+
+// TransactionTimeout is the time limit for retrying a transaction, the same as the driver's convenient transaction API
+var TransactionTimeout = 120 * time.Second
+
+func NewTransactionOptions(readConcern string, writeConcern string) *options.TransactionOptions {
+	opts := options.Transaction()
+	if rc := NewReadConcern(readConcern); rc != nil {
+		opts.SetReadConcern(rc)
+	}
+	if wc := NewWriteConcern(writeConcern, nil, 0); wc != nil {
+		opts.SetWriteConcern(wc)
+	}
+	return opts
+}
+
+// WithTransaction runs fn in a multi-document transaction.
+// The ctx passed to fn carries the session, so Repository, Adapter, Dao and batch functions called with it join the transaction.
+// The whole transaction is retried on TransientTransactionError, and the commit is retried on UnknownTransactionCommitResult.
+func WithTransaction(ctx context.Context, client *mongo.Client, fn func(ctx context.Context) error, opts ...*options.TransactionOptions) error {
+	session, err := client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+	txOpts := options.MergeTransactionOptions(opts...)
+	deadline := time.Now().Add(TransactionTimeout)
+	sc := mongo.NewSessionContext(ctx, session)
+	for {
+		if err = session.StartTransaction(txOpts); err != nil {
+			return err
+		}
+		if err = fn(sc); err != nil {
+			_ = session.AbortTransaction(sc)
+			if HasErrorLabel(err, TransientTransactionError) && time.Now().Before(deadline) {
+				continue
+			}
+			return err
+		}
+		err = commit(sc, session, deadline)
+		if err != nil && HasErrorLabel(err, TransientTransactionError) && time.Now().Before(deadline) {
+			continue
+		}
+		return err
+	}
+}
+func WithTransactionInDatabase(ctx context.Context, db *mongo.Database, fn func(ctx context.Context) error, opts ...*options.TransactionOptions) error {
+	return WithTransaction(ctx, db.Client(), fn, opts...)
+}
+func commit(ctx context.Context, session mongo.Session, deadline time.Time) error {
+	for {
+		err := session.CommitTransaction(ctx)
+		if err == nil || !HasErrorLabel(err, UnknownTransactionCommitResult) || !time.Now().Before(deadline) {
+			return err
+		}
+	}
+}
+func HasErrorLabel(err error, label string) bool {
+	var le mongo.LabeledError
+	if errors.As(err, &le) {
+		return le.HasErrorLabel(label)
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package mongo
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// WithTimeout returns the context with the timeout, if the timeout is positive and the context has no deadline
+func WithTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// Options is embedded into the repositories, queries and writers, to set the options of their collection and the default timeout of each call.
+// It refers to the collection field of its owner, so the owner must be created by its constructor and must not be copied.
+type Options struct {
+	collection **mongo.Collection
+	timeout    time.Duration
+}
+
+// NewOptions returns the options of the collection field of the owner, such as NewOptions(&repo.Collection)
+func NewOptions(collection **mongo.Collection) Options {
+	return Options{collection: collection}
+}
+
+// SetCollectionOptions sets the read preference, read concern and write concern of the collection, such as by client.NewCollectionOptions
+func (o *Options) SetCollectionOptions(opts ...*options.CollectionOptions) error {
+	collection, err := (*o.collection).Clone(opts...)
+	if err != nil {
+		return err
+	}
+	*o.collection = collection
+	return nil
+}
+
+// SetTimeout sets the default timeout of each call, which is applied when the context has no deadline
+func (o *Options) SetTimeout(timeout time.Duration) {
+	o.timeout = timeout
+}
+func (o *Options) Timeout() time.Duration {
+	return o.timeout
+}
+
+// SearchOptions are the options of the collection of the searches, such as secondaryPreferred read preference, so that the searches can go to the secondaries, while the writes stay on the primary.
+// The collection of the searches is cloned from the current collection, so that it also has the options which are set to the collection later, such as by SetCollectionOptions.
+type SearchOptions struct {
+	options    []*options.CollectionOptions
+	mu         sync.Mutex
+	base       *mongo.Collection
+	collection *mongo.Collection
+}
+
+func (s *SearchOptions) Set(collection *mongo.Collection, opts ...*options.CollectionOptions) error {
+	c, err := collection.Clone(opts...)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.options = opts
+	s.base = collection
+	s.collection = c
+	return nil
+}
+
+// Collection returns the collection of the searches, which is cloned again when the collection is changed
+func (s *SearchOptions) Collection(collection *mongo.Collection) *mongo.Collection {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.collection == nil {
+		return collection
+	}
+	if s.base != collection {
+		c, err := collection.Clone(s.options...)
+		if err != nil {
+			return collection
+		}
+		s.base = collection
+		s.collection = c
+	}
+	return s.collection
+}
@@ -6,6 +6,7 @@ import (
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 
 	mgo "github.com/core-go/mongo"
 )
@@ -16,6 +17,7 @@ type SearchDao[T any, K any, F any] struct {
 	GetSort    func(m interface{}) string
 	BuildSort  func(s string, modelType reflect.Type) bson.D
 	ModelType  reflect.Type
+	search     mgo.SearchOptions
 }
 
 func NewSearchDaoWithSortAndVersion[T any, K any, F any](db *mongo.Database, collectionName string, buildQuery func(m F) (bson.D, bson.M), getSort func(interface{}) string, buildSort func(string, reflect.Type) bson.D, idObjectId bool, versionField string, options ...Mapper[T]) *SearchDao[T, K, F] {
@@ -38,6 +40,8 @@ func NewSearchDao[T any, K any, F any](db *mongo.Database, collectionName string
 	return &SearchDao[T, K, F]{Dao: daobj, BuildSort: mgo.BuildSort, GetSort: getSort, BuildQuery: buildQuery, ModelType: modelType}
 }
 func (b *SearchDao[T, K, F]) Search(ctx context.Context, m F, limit int64, skip int64) ([]T, int64, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, b.Timeout())
+	defer cancel()
 	var objs []T
 	query, fields := b.BuildQuery(m)
 
@@ -49,12 +53,23 @@ func (b *SearchDao[T, K, F]) Search(ctx context.Context, m F, limit int64, skip
 	}
 	var total int64
 	var err error
-	total, err = mgo.BuildSearchResult(ctx, b.Collection, &objs, query, fields, sort, limit, skip)
+	total, err = mgo.BuildSearchResult(ctx, b.searchCollection(), &objs, query, fields, sort, limit, skip)
 	if b.Mapper != nil {
 		l := len(objs)
 		for i := 0; i < l; i++ {
 			b.Mapper.DbToModel(&objs[i])
 		}
 	}
+	if err == nil {
+		err = mgo.RunAfterLoads(ctx, b.Hooks, objs)
+	}
 	return objs, total, err
 }
+
+// SetSearchOptions sets the options of the collection of Search, such as secondaryPreferred read preference
+func (b *SearchDao[T, K, F]) SetSearchOptions(opts ...*options.CollectionOptions) error {
+	return b.search.Set(b.Collection, opts...)
+}
+func (b *SearchDao[T, K, F]) searchCollection() *mongo.Collection {
+	return b.search.Collection(b.Collection)
+}
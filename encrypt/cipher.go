@@ -0,0 +1,111 @@
+package encrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// Prefix is the prefix of the encrypted values, which are formatted as "enc:<key id>:<base64 of nonce and ciphertext>"
+const Prefix = "enc:"
+
+var ErrInvalidCiphertext = errors.New("invalid ciphertext")
+
+func IsEncrypted(s string) bool {
+	return strings.HasPrefix(s, Prefix)
+}
+
+// Encrypt encrypts plaintext by AES-GCM with the current key. If deterministic is true, the nonce is derived from the key, the context (such as the field name) and the plaintext,
+// so that the same value always has the same ciphertext, and can be searched by equality.
+func Encrypt(provider KeyProvider, plaintext string, deterministic bool, context string) (string, error) {
+	id, key, err := provider.CurrentKey()
+	if err != nil {
+		return "", err
+	}
+	return encrypt(id, key, plaintext, deterministic, context)
+}
+
+// EncryptAll encrypts plaintext deterministically with all keys, to search the values which were encrypted by the old keys
+func EncryptAll(provider KeyProvider, plaintext string, context string) ([]string, error) {
+	ids := provider.KeyIds()
+	res := make([]string, 0, len(ids))
+	for _, id := range ids {
+		key, err := provider.GetKey(id)
+		if err != nil {
+			return nil, err
+		}
+		s, err := encrypt(id, key, plaintext, true, context)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, s)
+	}
+	return res, nil
+}
+func Decrypt(provider KeyProvider, s string) (string, error) {
+	if !IsEncrypted(s) {
+		return "", ErrInvalidCiphertext
+	}
+	parts := strings.SplitN(s[len(Prefix):], ":", 2)
+	if len(parts) != 2 {
+		return "", ErrInvalidCiphertext
+	}
+	key, err := provider.GetKey(parts[0])
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", ErrInvalidCiphertext
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	n := gcm.NonceSize()
+	if len(data) < n {
+		return "", ErrInvalidCiphertext
+	}
+	plaintext, err := gcm.Open(nil, data[:n], data[n:], []byte(parts[0]))
+	if err != nil {
+		return "", ErrInvalidCiphertext
+	}
+	return string(plaintext), nil
+}
+func encrypt(id string, key []byte, plaintext string, deterministic bool, context string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if deterministic {
+		mac := hmac.New(sha256.New, derive(key, "deterministic"))
+		mac.Write([]byte(context))
+		mac.Write([]byte{0})
+		mac.Write([]byte(plaintext))
+		copy(nonce, mac.Sum(nil))
+	} else if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	data := gcm.Seal(nonce, nonce, []byte(plaintext), []byte(id))
+	return Prefix + id + ":" + base64.RawURLEncoding.EncodeToString(data), nil
+}
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// derive makes a separate key for the nonce, so that the encryption key is not used for two purposes
+func derive(key []byte, label string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(label))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,110 @@
+package encrypt
+
+import (
+	"bytes"
+	"testing"
+)
+
+func newProvider(t *testing.T, current string) *LocalKeyProvider {
+	p, err := NewLocalKeyProvider(current, map[string][]byte{
+		"k1": bytes.Repeat([]byte{1}, 32),
+		"k2": bytes.Repeat([]byte{2}, 16),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	p := newProvider(t, "k1")
+	tests := []struct {
+		name          string
+		plaintext     string
+		deterministic bool
+	}{
+		{"random", "secret", false},
+		{"deterministic", "secret", true},
+		{"empty", "", false},
+		{"unicode", "mật khẩu", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c1, err := Encrypt(p, tt.plaintext, tt.deterministic, "email")
+			if err != nil {
+				t.Fatal(err)
+			}
+			c2, err := Encrypt(p, tt.plaintext, tt.deterministic, "email")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !IsEncrypted(c1) {
+				t.Errorf("%s has no prefix", c1)
+			}
+			if (c1 == c2) != tt.deterministic {
+				t.Errorf("same ciphertext = %v, want %v", c1 == c2, tt.deterministic)
+			}
+			s, err := Decrypt(p, c1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s != tt.plaintext {
+				t.Errorf("Decrypt() = %q, want %q", s, tt.plaintext)
+			}
+		})
+	}
+}
+
+func TestDeterministicContext(t *testing.T) {
+	p := newProvider(t, "k1")
+	a, _ := Encrypt(p, "x", true, "email")
+	b, _ := Encrypt(p, "x", true, "phone")
+	if a == b {
+		t.Error("the ciphertexts of the different contexts must be different")
+	}
+}
+
+func TestRotation(t *testing.T) {
+	old := newProvider(t, "k2")
+	c, err := Encrypt(old, "secret", true, "email")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newProvider(t, "k1")
+	s, err := Decrypt(p, c)
+	if err != nil || s != "secret" {
+		t.Fatalf("Decrypt() = %q, %v", s, err)
+	}
+	all, err := EncryptAll(p, "secret", "email")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 || all[1] != c {
+		t.Errorf("EncryptAll() = %v, want the ciphertext of the old key %s", all, c)
+	}
+}
+
+func TestDecryptInvalid(t *testing.T) {
+	p := newProvider(t, "k1")
+	c, _ := Encrypt(p, "secret", false, "")
+	tests := []struct {
+		name string
+		s    string
+		err  error
+	}{
+		{"plaintext", "secret", ErrInvalidCiphertext},
+		{"no key id", Prefix + "abc", ErrInvalidCiphertext},
+		{"unknown key", Prefix + "k3:abc", ErrKeyNotFound},
+		{"bad base64", Prefix + "k1:***", ErrInvalidCiphertext},
+		{"short", Prefix + "k1:AAAA", ErrInvalidCiphertext},
+		{"tampered", c[:len(c)-2] + "AA", ErrInvalidCiphertext},
+		{"other key id", Prefix + "k2:" + c[len(Prefix)+3:], ErrInvalidCiphertext},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decrypt(p, tt.s); err != tt.err {
+				t.Errorf("Decrypt() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package encrypt
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrKeyNotFound = errors.New("encryption key is not found")
+
+// KeyProvider provides the AES keys by id. New values are encrypted by the current key, and the key id is stored as the prefix of the ciphertext,
+// so that the old keys are still used to decrypt the old values after the keys are rotated.
+type KeyProvider interface {
+	CurrentKey() (string, []byte, error)
+	GetKey(id string) ([]byte, error)
+	KeyIds() []string
+}
+
+// LocalKeyProvider keeps the keys in memory. The keys must be 16, 24 or 32 bytes, for AES-128, AES-192 or AES-256.
+type LocalKeyProvider struct {
+	current string
+	keys    map[string][]byte
+	ids     []string
+}
+
+func NewLocalKeyProvider(current string, keys map[string][]byte) (*LocalKeyProvider, error) {
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("current key %s is not in keys", current)
+	}
+	p := &LocalKeyProvider{current: current, keys: make(map[string][]byte)}
+	p.ids = append(p.ids, current)
+	for id, key := range keys {
+		if len(id) == 0 || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("invalid key id '%s'", id)
+		}
+		if l := len(key); l != 16 && l != 24 && l != 32 {
+			return nil, fmt.Errorf("key %s must be 16, 24 or 32 bytes", id)
+		}
+		p.keys[id] = key
+		if id != current {
+			p.ids = append(p.ids, id)
+		}
+	}
+	return p, nil
+}
+func (p *LocalKeyProvider) CurrentKey() (string, []byte, error) {
+	return p.current, p.keys[p.current], nil
+}
+func (p *LocalKeyProvider) GetKey(id string) ([]byte, error) {
+	key, ok := p.keys[id]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return key, nil
+}
+
+// KeyIds returns the current key id first
+func (p *LocalKeyProvider) KeyIds() []string {
+	return p.ids
+}
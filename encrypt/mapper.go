@@ -0,0 +1,149 @@
+package encrypt
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+)
+
+type field struct {
+	index         int
+	json          string
+	bson          string
+	deterministic bool
+}
+
+// FieldMapper encrypts the string and *string fields which are tagged `encrypt:"true"` or `encrypt:"deterministic"`.
+// The deterministic fields can be searched by equality, with the query built by UseQuery.
+// The values without Prefix are not decrypted, so that the existing plaintext values can be read until they are encrypted.
+type FieldMapper[T any] struct {
+	Provider KeyProvider
+	fields   []field
+}
+
+func NewMapper[T any](provider KeyProvider) *FieldMapper[T] {
+	var t T
+	modelType := reflect.TypeOf(t)
+	if modelType.Kind() != reflect.Struct {
+		panic("T must be a struct")
+	}
+	if _, _, err := provider.CurrentKey(); err != nil {
+		panic(err)
+	}
+	return &FieldMapper[T]{Provider: provider, fields: findEncryptedFields(modelType)}
+}
+func findEncryptedFields(modelType reflect.Type) []field {
+	fields := make([]field, 0)
+	numField := modelType.NumField()
+	for i := 0; i < numField; i++ {
+		f := modelType.Field(i)
+		tag, ok := f.Tag.Lookup("encrypt")
+		if !ok || (tag != "true" && tag != "deterministic") {
+			continue
+		}
+		if t := f.Type.String(); t != "string" && t != "*string" {
+			panic(f.Name + " must be string or *string to be encrypted")
+		}
+		x := field{index: i, json: f.Name, bson: strings.ToLower(f.Name), deterministic: tag == "deterministic"}
+		if s, ok := f.Tag.Lookup("json"); ok && len(strings.Split(s, ",")[0]) > 0 {
+			x.json = strings.Split(s, ",")[0]
+		}
+		if s, ok := f.Tag.Lookup("bson"); ok && len(strings.Split(s, ",")[0]) > 0 {
+			x.bson = strings.Split(s, ",")[0]
+		}
+		fields = append(fields, x)
+	}
+	return fields
+}
+
+// ModelToDb encrypts the fields. It panics if the value cannot be encrypted, so that the plaintext is never stored.
+// Repository, Adapter and Dao call TryModelToDb, to return the error.
+func (s *FieldMapper[T]) ModelToDb(model *T) {
+	if err := s.TryModelToDb(model); err != nil {
+		panic(err)
+	}
+}
+func (s *FieldMapper[T]) TryModelToDb(model *T) error {
+	rv := reflect.Indirect(reflect.ValueOf(model))
+	for _, f := range s.fields {
+		v := rv.Field(f.index)
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				continue
+			}
+			v = v.Elem()
+		}
+		if v.Len() == 0 {
+			continue
+		}
+		c, err := s.encrypt(f, v.String())
+		if err != nil {
+			return err
+		}
+		v.SetString(c)
+	}
+	return nil
+}
+
+// DbToModel decrypts the fields. The values which cannot be decrypted are kept.
+func (s *FieldMapper[T]) DbToModel(model *T) {
+	rv := reflect.Indirect(reflect.ValueOf(model))
+	for _, f := range s.fields {
+		v := rv.Field(f.index)
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				continue
+			}
+			v = v.Elem()
+		}
+		if !IsEncrypted(v.String()) {
+			continue
+		}
+		if plaintext, err := Decrypt(s.Provider, v.String()); err == nil {
+			v.SetString(plaintext)
+		}
+	}
+}
+func (s *FieldMapper[T]) MapToDb(m map[string]interface{}) map[string]interface{} {
+	res, err := s.TryMapToDb(m)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+func (s *FieldMapper[T]) TryMapToDb(m map[string]interface{}) (map[string]interface{}, error) {
+	for _, f := range s.fields {
+		var plaintext string
+		switch v := m[f.json].(type) {
+		case string:
+			plaintext = v
+		case *string:
+			if v != nil {
+				plaintext = *v
+			}
+		}
+		if len(plaintext) == 0 {
+			continue
+		}
+		c, err := s.encrypt(f, plaintext)
+		if err != nil {
+			return nil, err
+		}
+		m[f.json] = c
+	}
+	return m, nil
+}
+
+// encrypt keeps the value if it can be decrypted by the keys, so that the plaintext which starts with Prefix is encrypted too
+func (s *FieldMapper[T]) encrypt(f field, plaintext string) (string, error) {
+	if IsEncrypted(plaintext) {
+		_, err := Decrypt(s.Provider, plaintext)
+		if err == nil {
+			return plaintext, nil
+		}
+		if !errors.Is(err, ErrInvalidCiphertext) && !errors.Is(err, ErrKeyNotFound) {
+			return "", err
+		}
+	}
+	return Encrypt(s.Provider, plaintext, f.deterministic, f.bson)
+}
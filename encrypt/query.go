@@ -0,0 +1,205 @@
+package encrypt
+
+import (
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrUnsupportedQuery = errors.New("the query of the encrypted field is not supported")
+
+// UseQuery wraps the query builder, such as query.UseQuery, to search the deterministic fields of T by equality.
+// The values of these fields are replaced by $in of the ciphertexts of all keys, and $ne and $nin by $nin. Because the ciphertext cannot be matched partially,
+// the prefix and "like" regular expressions of query.Build are matched as the whole value. The random encrypted fields cannot be searched.
+// If the values cannot be encrypted, or the operators cannot be applied to the ciphertexts, such as $gt, the query matches nothing, so that the plaintext is never compared with the ciphertexts.
+func UseQuery[T any, F any](mapper *FieldMapper[T], buildQuery func(F) (bson.D, bson.M)) func(F) (bson.D, bson.M) {
+	names := make(map[string]string)
+	for _, f := range mapper.fields {
+		if f.deterministic {
+			names[f.bson] = f.bson
+		}
+	}
+	return func(filter F) (bson.D, bson.M) {
+		query, fields := buildQuery(filter)
+		res, err := encryptQuery(mapper.Provider, names, query)
+		if err != nil {
+			return bson.D{{Key: "_id", Value: bson.M{"$in": bson.A{}}}}, fields
+		}
+		return res, fields
+	}
+}
+func encryptQuery(provider KeyProvider, names map[string]string, query bson.D) (bson.D, error) {
+	res := make(bson.D, 0, len(query))
+	for _, e := range query {
+		v, err := encryptValue(provider, names, e.Key, e.Value)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, bson.E{Key: e.Key, Value: v})
+	}
+	return res, nil
+}
+func encryptMap(provider KeyProvider, names map[string]string, m bson.M) (bson.M, error) {
+	res := bson.M{}
+	for k, v := range m {
+		x, err := encryptValue(provider, names, k, v)
+		if err != nil {
+			return nil, err
+		}
+		res[k] = x
+	}
+	return res, nil
+}
+func encryptValue(provider KeyProvider, names map[string]string, key string, value interface{}) (interface{}, error) {
+	if key == "$or" || key == "$and" || key == "$nor" {
+		return encryptArray(provider, names, value)
+	}
+	context, ok := names[key]
+	if !ok || value == nil {
+		return value, nil
+	}
+	switch v := value.(type) {
+	case bson.M:
+		res := bson.M{}
+		for op, x := range v {
+			k, y, err := encryptOperator(provider, context, op, x)
+			if err != nil {
+				return nil, err
+			}
+			if _, exist := res[k]; exist {
+				return nil, ErrUnsupportedQuery
+			}
+			res[k] = y
+		}
+		return res, nil
+	case bson.D:
+		res := make(bson.D, 0, len(v))
+		for _, e := range v {
+			k, y, err := encryptOperator(provider, context, e.Key, e.Value)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, bson.E{Key: k, Value: y})
+		}
+		return res, nil
+	}
+	_, y, err := encryptOperator(provider, context, "$eq", value)
+	if err != nil {
+		return nil, err
+	}
+	return bson.M{"$in": y}, nil
+}
+func encryptArray(provider KeyProvider, names map[string]string, value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case []bson.M:
+		arr := make([]bson.M, 0, len(v))
+		for _, m := range v {
+			x, err := encryptMap(provider, names, m)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, x)
+		}
+		return arr, nil
+	case []bson.D:
+		arr := make([]bson.D, 0, len(v))
+		for _, d := range v {
+			x, err := encryptQuery(provider, names, d)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, x)
+		}
+		return arr, nil
+	case bson.A:
+		arr := make(bson.A, 0, len(v))
+		for _, x := range v {
+			var y interface{}
+			var err error
+			switch z := x.(type) {
+			case bson.M:
+				y, err = encryptMap(provider, names, z)
+			case bson.D:
+				y, err = encryptQuery(provider, names, z)
+			default:
+				y = x
+			}
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, y)
+		}
+		return arr, nil
+	}
+	return value, nil
+}
+
+// encryptOperator replaces $eq and $in by $in, $ne and $nin by $nin, of the ciphertexts of all keys
+func encryptOperator(provider KeyProvider, context string, op string, value interface{}) (string, interface{}, error) {
+	switch op {
+	case "$exists":
+		return op, value, nil
+	case "$eq", "$in", "$ne", "$nin":
+	default:
+		return "", nil, ErrUnsupportedQuery
+	}
+	if value == nil && (op == "$eq" || op == "$ne") {
+		return op, value, nil
+	}
+	values, ok := toStrings(value)
+	if !ok {
+		return "", nil, ErrUnsupportedQuery
+	}
+	arr := make([]string, 0)
+	for _, v := range values {
+		c, err := EncryptAll(provider, v, context)
+		if err != nil {
+			return "", nil, err
+		}
+		arr = append(arr, c...)
+	}
+	if op == "$ne" || op == "$nin" {
+		return "$nin", arr, nil
+	}
+	return "$in", arr, nil
+}
+func toStrings(value interface{}) ([]string, bool) {
+	switch v := value.(type) {
+	case string:
+		return []string{v}, true
+	case *string:
+		if v == nil {
+			return nil, false
+		}
+		return []string{*v}, true
+	case primitive.Regex:
+		return []string{trimPattern(v.Pattern)}, true
+	case []string:
+		return v, true
+	case bson.A:
+		return toStrings([]interface{}(v))
+	case []interface{}:
+		res := make([]string, 0, len(v))
+		for _, x := range v {
+			s, ok := toStrings(x)
+			if !ok || len(s) != 1 {
+				return nil, false
+			}
+			res = append(res, s[0])
+		}
+		return res, true
+	}
+	return nil, false
+}
+
+// trimPattern removes the anchors of the regular expressions of query.Build
+func trimPattern(pattern string) string {
+	if len(pattern) > 0 && pattern[0] == '^' {
+		return pattern[1:]
+	}
+	if len(pattern) >= 6 && pattern[:3] == `\w*` && pattern[len(pattern)-3:] == `\w*` {
+		return pattern[3 : len(pattern)-3]
+	}
+	return pattern
+}
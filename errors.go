@@ -0,0 +1,88 @@
+package mongo
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrNotFound        = errors.New("not found")
+	ErrDuplicateKey    = errors.New("duplicate key")
+	ErrVersionConflict = errors.New("version conflict")
+)
+
+// DuplicateKeyError is returned instead of the driver error for E11000, and matches ErrDuplicateKey with errors.Is
+type DuplicateKeyError struct {
+	Index string
+	Keys  map[string]interface{}
+	Err   error
+}
+
+func (e *DuplicateKeyError) Error() string {
+	if len(e.Index) > 0 {
+		return fmt.Sprintf("duplicate key on index %s: %v", e.Index, e.Keys)
+	}
+	return ErrDuplicateKey.Error()
+}
+func (e *DuplicateKeyError) Is(target error) bool {
+	return target == ErrDuplicateKey
+}
+func (e *DuplicateKeyError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	indexPattern  = regexp.MustCompile(`index: (\S+)`)
+	dupKeyPattern = regexp.MustCompile(`dup key: \{(.*)\}`)
+	keyPattern    = regexp.MustCompile(`([\w.$]+): ("(?:[^"\\]|\\.)*"|[^,]+)`)
+)
+
+// ToDuplicateKeyError parses the index name and key values of a duplicate key error.
+// The key values are read from the keyValue field of the write error, or from the E11000 message of old servers.
+func ToDuplicateKeyError(err error) (*DuplicateKeyError, bool) {
+	if err == nil || !mongo.IsDuplicateKeyError(err) {
+		return nil, false
+	}
+	msg := err.Error()
+	var raw bson.Raw
+	var we mongo.WriteException
+	var be mongo.BulkWriteException
+	var ce mongo.CommandError
+	if errors.As(err, &we) && len(we.WriteErrors) > 0 {
+		msg = we.WriteErrors[0].Message
+		raw = we.WriteErrors[0].Raw
+	} else if errors.As(err, &be) && len(be.WriteErrors) > 0 {
+		msg = be.WriteErrors[0].Message
+		raw = be.WriteErrors[0].Raw
+	} else if errors.As(err, &ce) {
+		msg = ce.Message
+		raw = ce.Raw
+	}
+	e := &DuplicateKeyError{Err: err, Keys: make(map[string]interface{})}
+	if m := indexPattern.FindStringSubmatch(msg); len(m) > 1 {
+		e.Index = m[1]
+	}
+	if raw != nil {
+		if v, er1 := raw.LookupErr("keyValue"); er1 == nil {
+			if er2 := v.Unmarshal(&e.Keys); er2 == nil && len(e.Keys) > 0 {
+				return e, true
+			}
+		}
+	}
+	if m := dupKeyPattern.FindStringSubmatch(msg); len(m) > 1 {
+		for _, kv := range keyPattern.FindAllStringSubmatch(m[1], -1) {
+			v := strings.TrimSpace(kv[2])
+			if strings.HasPrefix(v, `"`) && strings.HasSuffix(v, `"`) && len(v) >= 2 {
+				e.Keys[kv[1]] = v[1 : len(v)-1]
+			} else {
+				e.Keys[kv[1]] = v
+			}
+		}
+	}
+	return e, true
+}
@@ -0,0 +1,164 @@
+package mongo
+
+import (
+	"context"
+	"reflect"
+)
+
+// The model can implement these interfaces. An error returned by a Before hook aborts the operation.
+type BeforeCreate interface {
+	BeforeCreate(ctx context.Context) error
+}
+type AfterCreate interface {
+	AfterCreate(ctx context.Context) error
+}
+type BeforeUpdate interface {
+	BeforeUpdate(ctx context.Context) error
+}
+type AfterUpdate interface {
+	AfterUpdate(ctx context.Context) error
+}
+type AfterLoad interface {
+	AfterLoad(ctx context.Context) error
+}
+
+// BeforePatch and BeforeDelete do not receive the model, so they are called on the zero value of the model
+type BeforePatch interface {
+	BeforePatch(ctx context.Context, model map[string]interface{}) error
+}
+type BeforeDelete interface {
+	BeforeDelete(ctx context.Context, id interface{}) error
+}
+
+// Hooks are the callbacks registered to Repository, Adapter and Dao, which are called after the hooks implemented by the model
+type Hooks[T any] struct {
+	BeforeCreate func(ctx context.Context, model *T) error
+	AfterCreate  func(ctx context.Context, model *T) error
+	BeforeUpdate func(ctx context.Context, model *T) error
+	AfterUpdate  func(ctx context.Context, model *T) error
+	BeforePatch  func(ctx context.Context, model map[string]interface{}) error
+	BeforeDelete func(ctx context.Context, id interface{}) error
+	AfterLoad    func(ctx context.Context, model *T) error
+}
+
+func RunBeforeCreate[T any](ctx context.Context, hooks *Hooks[T], model *T) error {
+	if err := CallBeforeCreate(ctx, model); err != nil {
+		return err
+	}
+	if hooks != nil && hooks.BeforeCreate != nil {
+		return hooks.BeforeCreate(ctx, model)
+	}
+	return nil
+}
+func RunAfterCreate[T any](ctx context.Context, hooks *Hooks[T], model *T) error {
+	if err := CallAfterCreate(ctx, model); err != nil {
+		return err
+	}
+	if hooks != nil && hooks.AfterCreate != nil {
+		return hooks.AfterCreate(ctx, model)
+	}
+	return nil
+}
+func RunBeforeUpdate[T any](ctx context.Context, hooks *Hooks[T], model *T) error {
+	if err := CallBeforeUpdate(ctx, model); err != nil {
+		return err
+	}
+	if hooks != nil && hooks.BeforeUpdate != nil {
+		return hooks.BeforeUpdate(ctx, model)
+	}
+	return nil
+}
+func RunAfterUpdate[T any](ctx context.Context, hooks *Hooks[T], model *T) error {
+	if err := CallAfterUpdate(ctx, model); err != nil {
+		return err
+	}
+	if hooks != nil && hooks.AfterUpdate != nil {
+		return hooks.AfterUpdate(ctx, model)
+	}
+	return nil
+}
+func RunBeforePatch[T any](ctx context.Context, hooks *Hooks[T], model map[string]interface{}) error {
+	var t T
+	if h, ok := interface{}(&t).(BeforePatch); ok {
+		if err := h.BeforePatch(ctx, model); err != nil {
+			return err
+		}
+	}
+	if hooks != nil && hooks.BeforePatch != nil {
+		return hooks.BeforePatch(ctx, model)
+	}
+	return nil
+}
+func RunBeforeDelete[T any](ctx context.Context, hooks *Hooks[T], id interface{}) error {
+	var t T
+	if h, ok := interface{}(&t).(BeforeDelete); ok {
+		if err := h.BeforeDelete(ctx, id); err != nil {
+			return err
+		}
+	}
+	if hooks != nil && hooks.BeforeDelete != nil {
+		return hooks.BeforeDelete(ctx, id)
+	}
+	return nil
+}
+func RunAfterLoad[T any](ctx context.Context, hooks *Hooks[T], model *T) error {
+	if err := CallAfterLoad(ctx, model); err != nil {
+		return err
+	}
+	if hooks != nil && hooks.AfterLoad != nil {
+		return hooks.AfterLoad(ctx, model)
+	}
+	return nil
+}
+func RunAfterLoads[T any](ctx context.Context, hooks *Hooks[T], models []T) error {
+	l := len(models)
+	for i := 0; i < l; i++ {
+		if err := RunAfterLoad(ctx, hooks, &models[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CallBeforeCreate, CallAfterCreate, CallBeforeUpdate, CallAfterUpdate and CallAfterLoad call the hooks implemented by the model.
+// model should be a pointer, and can be a pointer to pointer, for writers of which T is a pointer.
+func CallBeforeCreate(ctx context.Context, model interface{}) error {
+	if h, ok := hookTarget(model).(BeforeCreate); ok {
+		return h.BeforeCreate(ctx)
+	}
+	return nil
+}
+func CallAfterCreate(ctx context.Context, model interface{}) error {
+	if h, ok := hookTarget(model).(AfterCreate); ok {
+		return h.AfterCreate(ctx)
+	}
+	return nil
+}
+func CallBeforeUpdate(ctx context.Context, model interface{}) error {
+	if h, ok := hookTarget(model).(BeforeUpdate); ok {
+		return h.BeforeUpdate(ctx)
+	}
+	return nil
+}
+func CallAfterUpdate(ctx context.Context, model interface{}) error {
+	if h, ok := hookTarget(model).(AfterUpdate); ok {
+		return h.AfterUpdate(ctx)
+	}
+	return nil
+}
+func CallAfterLoad(ctx context.Context, model interface{}) error {
+	if h, ok := hookTarget(model).(AfterLoad); ok {
+		return h.AfterLoad(ctx)
+	}
+	return nil
+}
+func hookTarget(model interface{}) interface{} {
+	v := reflect.ValueOf(model)
+	for v.Kind() == reflect.Ptr && !v.IsNil() && v.Elem().Kind() == reflect.Ptr && !v.Elem().IsNil() {
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return nil
+	}
+	return v.Interface()
+}
@@ -0,0 +1,158 @@
+package mongo
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/x/bsonx/bsoncore"
+)
+
+// UUID is stored as BSON binary subtype 4, and as the canonical string in json
+type UUID [16]byte
+
+var ErrInvalidUUID = errors.New("invalid UUID")
+
+// GenerateUUID generates a random (version 4) UUID
+func GenerateUUID() UUID {
+	var u UUID
+	_, _ = rand.Read(u[:])
+	u[6] = (u[6] & 0x0f) | 0x40
+	u[8] = (u[8] & 0x3f) | 0x80
+	return u
+}
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	b, err := hex.DecodeString(strings.ReplaceAll(s, "-", ""))
+	if err != nil || len(b) != 16 {
+		return u, ErrInvalidUUID
+	}
+	copy(u[:], b)
+	return u, nil
+}
+func (u UUID) String() string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
+func (u UUID) IsZero() bool {
+	return u == UUID{}
+}
+func (u UUID) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	return bson.TypeBinary, bsoncore.AppendBinary(nil, bson.TypeBinaryUUID, u[:]), nil
+}
+func (u *UUID) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	if t != bson.TypeBinary {
+		return fmt.Errorf("cannot decode %v into UUID", t)
+	}
+	subtype, b, _, ok := bsoncore.ReadBinary(data)
+	if !ok || (subtype != bson.TypeBinaryUUID && subtype != bson.TypeBinaryUUIDOld) || len(b) != 16 {
+		return ErrInvalidUUID
+	}
+	copy(u[:], b)
+	return nil
+}
+func (u UUID) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+func (u *UUID) UnmarshalText(b []byte) error {
+	v, err := ParseUUID(string(b))
+	if err != nil {
+		return err
+	}
+	*u = v
+	return nil
+}
+
+// The id strategies of Create. By default, ObjectID and UUID ids are generated by the client, and the string ids are generated by the server as ObjectID and returned as hex.
+const (
+	IdDefault  = ""
+	IdObjectId = "objectId"
+	IdUUID     = "uuid"
+)
+
+// NewId generates an id of the id type. The string ids are generated only for IdObjectId (hex) and IdUUID strategies.
+func NewId(idType reflect.Type, strategy string) (interface{}, bool) {
+	if idType.Kind() == reflect.Ptr {
+		v, ok := NewId(idType.Elem(), strategy)
+		if !ok {
+			return nil, false
+		}
+		p := reflect.New(idType.Elem())
+		p.Elem().Set(reflect.ValueOf(v))
+		return p.Interface(), true
+	}
+	switch idType {
+	case reflect.TypeOf(primitive.ObjectID{}):
+		return primitive.NewObjectID(), true
+	case reflect.TypeOf(UUID{}):
+		return GenerateUUID(), true
+	}
+	if idType.Kind() == reflect.String {
+		switch strategy {
+		case IdObjectId:
+			return reflect.ValueOf(primitive.NewObjectID().Hex()).Convert(idType).Interface(), true
+		case IdUUID:
+			return reflect.ValueOf(NewUUID()).Convert(idType).Interface(), true
+		}
+	}
+	return nil, false
+}
+
+// ToId converts the id to the value of _id. ObjectID, UUID and composite keys are kept. If objectId is true, the other ids are converted from hex to ObjectID.
+func ToId(id interface{}, objectId bool) (interface{}, error) {
+	switch v := id.(type) {
+	case primitive.ObjectID, UUID:
+		return v, nil
+	case *primitive.ObjectID:
+		return *v, nil
+	case *UUID:
+		return *v, nil
+	}
+	if objectId && reflect.ValueOf(id).Kind() != reflect.Struct {
+		return primitive.ObjectIDFromHex(fmt.Sprintf("%v", id))
+	}
+	return id, nil
+}
+
+// MapId converts the id of a json map, in which ObjectID and UUID are strings, to the stored id of K
+func MapId[K any](id interface{}, objectId bool) (interface{}, error) {
+	if s, ok := id.(string); ok {
+		var k K
+		switch interface{}(k).(type) {
+		case primitive.ObjectID:
+			return primitive.ObjectIDFromHex(s)
+		case UUID:
+			return ParseUUID(s)
+		}
+	}
+	return ToId(id, objectId)
+}
+
+// IsEmptyId returns true if the id is nil, an empty string, a zero ObjectID, a zero UUID or a zero composite key.
+// The numbers are not empty, so that 0 is a valid id, such as for Save.
+func IsEmptyId(id interface{}) bool {
+	if id == nil {
+		return true
+	}
+	v := reflect.ValueOf(id)
+	switch v.Kind() {
+	case reflect.Ptr:
+		return v.IsNil() || IsEmptyId(v.Elem().Interface())
+	case reflect.String, reflect.Array, reflect.Struct:
+		return v.IsZero()
+	}
+	return false
+}
+
+// IsCompositeId returns true if the id type is a struct, which is stored as a subdocument, except ObjectID and UUID which are arrays
+func IsCompositeId(idType reflect.Type) bool {
+	if idType.Kind() == reflect.Ptr {
+		idType = idType.Elem()
+	}
+	return idType.Kind() == reflect.Struct
+}
@@ -0,0 +1,436 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// IndexOptions are the options of EnsureIndexes. DryRun only reports the changes. DropStale drops the indexes which are not in the struct tags.
+// Partials are the partial filter expressions by index name, for the filters which cannot be written in the tag.
+type IndexOptions struct {
+	DryRun    bool
+	DropStale bool
+	Partials  map[string]interface{}
+}
+
+// IndexResult reports the index names. Changed are the indexes which have the same name, but different keys or options, and are recreated only if DropStale is true.
+// Missing are the changed indexes which are dropped, but cannot be created again, such as a new unique index of the duplicate values.
+type IndexResult struct {
+	Created []string
+	Dropped []string
+	Stale   []string
+	Changed []string
+	Missing []string
+}
+
+type indexField struct {
+	name  string
+	value interface{}
+	order int
+	seq   int
+}
+type indexSpec struct {
+	name        string
+	fields      []indexField
+	unique      bool
+	sparse      bool
+	expireAfter int32
+	partial     bson.M
+	hasTTL      bool
+}
+
+// BuildIndexes builds the indexes of the `index` tags of the model type. Each field can have many indexes, separated by ";".
+// The options of an index are separated by ",": unique, desc, sparse, text, 2dsphere, partial (only the documents with this field),
+// expireAfter=seconds or duration (TTL), name=index name (the fields of the same name are a compound index) and order=position in the compound index.
+// All text fields are in one text index, because a collection can have only one.
+// For example: `index:"unique"`, `index:"expireAfter=24h"` and `index:"name=status_createdAt,order=1;desc"`.
+func BuildIndexes(modelType reflect.Type) []mongo.IndexModel {
+	specs := buildIndexSpecs(modelType)
+	models := make([]mongo.IndexModel, 0, len(specs))
+	for _, s := range specs {
+		models = append(models, s.model(nil))
+	}
+	return models
+}
+
+// EnsureIndexes creates the indexes of the `index` tags of T which do not exist, and reports or drops the indexes which are not in the tags
+func EnsureIndexes[T any](ctx context.Context, collection *mongo.Collection, opts ...IndexOptions) (*IndexResult, error) {
+	var o IndexOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	var t T
+	specs := buildIndexSpecs(reflect.TypeOf(t))
+	cursor, err := collection.Indexes().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var existing []bson.Raw
+	if err = cursor.All(ctx, &existing); err != nil {
+		return nil, err
+	}
+	current := make(map[string]bson.M)
+	for _, raw := range existing {
+		var idx bson.M
+		if err = bson.Unmarshal(raw, &idx); err != nil {
+			return nil, err
+		}
+		// the key is decoded as bson.D, to compare the order of the fields
+		var key bson.D
+		if err = raw.Lookup("key").Unmarshal(&key); err != nil {
+			return nil, err
+		}
+		idx["key"] = key
+		if name, ok := idx["name"].(string); ok && name != "_id_" {
+			current[name] = idx
+		}
+	}
+	res := &IndexResult{}
+	creates := make([]mongo.IndexModel, 0)
+	recreates := make([]mongo.IndexModel, 0)
+	drops := make([]string, 0)
+	declared := make(map[string]bool)
+	for _, s := range specs {
+		declared[s.name] = true
+		var partial interface{}
+		if o.Partials != nil {
+			partial = o.Partials[s.name]
+		}
+		idx, ok := current[s.name]
+		if !ok {
+			res.Created = append(res.Created, s.name)
+			creates = append(creates, s.model(partial))
+			continue
+		}
+		if s.equal(idx, partial) {
+			continue
+		}
+		res.Changed = append(res.Changed, s.name)
+		if o.DropStale {
+			res.Dropped = append(res.Dropped, s.name)
+			res.Created = append(res.Created, s.name)
+			recreates = append(recreates, s.model(partial))
+		}
+	}
+	for name := range current {
+		if !declared[name] {
+			res.Stale = append(res.Stale, name)
+			if o.DropStale {
+				res.Dropped = append(res.Dropped, name)
+				drops = append(drops, name)
+			}
+		}
+	}
+	sort.Strings(res.Stale)
+	if o.DryRun {
+		return res, nil
+	}
+	// the stale indexes are dropped first, so that an index which is renamed can be created with the same keys
+	for _, name := range drops {
+		if _, err = collection.Indexes().DropOne(ctx, name); err != nil {
+			return res, err
+		}
+	}
+	// the changed indexes are recreated one by one, so that only the failed one is missing
+	for _, m := range recreates {
+		name := *m.Options.Name
+		if _, err = collection.Indexes().DropOne(ctx, name); err != nil {
+			return res, err
+		}
+		if _, err = collection.Indexes().CreateOne(ctx, m); err != nil {
+			res.Missing = append(res.Missing, name)
+			return res, err
+		}
+	}
+	if len(creates) > 0 {
+		if _, err = collection.Indexes().CreateMany(ctx, creates); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+func buildIndexSpecs(modelType reflect.Type) []*indexSpec {
+	if modelType.Kind() == reflect.Ptr {
+		modelType = modelType.Elem()
+	}
+	specs := make([]*indexSpec, 0)
+	groups := make(map[string]*indexSpec)
+	seq := 0
+	collectIndexes(modelType, "", &specs, groups, &seq, map[reflect.Type]bool{})
+	specs = mergeText(specs)
+	for _, s := range specs {
+		sort.SliceStable(s.fields, func(i, j int) bool {
+			if s.fields[i].order != s.fields[j].order {
+				return s.fields[i].order < s.fields[j].order
+			}
+			return s.fields[i].seq < s.fields[j].seq
+		})
+		if len(s.name) == 0 {
+			parts := make([]string, 0)
+			for _, f := range s.fields {
+				parts = append(parts, f.name+"_"+fmt.Sprintf("%v", f.value))
+			}
+			s.name = strings.Join(parts, "_")
+		}
+	}
+	return specs
+}
+
+// mergeText merges the indexes which have text fields into the first one, because a collection can have only one text index
+func mergeText(specs []*indexSpec) []*indexSpec {
+	var text *indexSpec
+	merged := make([]*indexSpec, 0, len(specs))
+	for _, s := range specs {
+		if !s.isText() {
+			merged = append(merged, s)
+			continue
+		}
+		if text == nil {
+			text = s
+			merged = append(merged, s)
+			continue
+		}
+		text.fields = append(text.fields, s.fields...)
+		text.unique = text.unique || s.unique
+		text.sparse = text.sparse || s.sparse
+		if len(text.name) == 0 {
+			text.name = s.name
+		}
+		if s.partial != nil {
+			if text.partial == nil {
+				text.partial = bson.M{}
+			}
+			for k, v := range s.partial {
+				text.partial[k] = v
+			}
+		}
+	}
+	return merged
+}
+func (s *indexSpec) isText() bool {
+	for _, f := range s.fields {
+		if f.value == "text" {
+			return true
+		}
+	}
+	return false
+}
+func collectIndexes(modelType reflect.Type, prefix string, specs *[]*indexSpec, groups map[string]*indexSpec, seq *int, visited map[reflect.Type]bool) {
+	visited[modelType] = true
+	defer delete(visited, modelType)
+	for i := 0; i < modelType.NumField(); i++ {
+		field := modelType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := strings.ToLower(field.Name)
+		inline := false
+		if tag, ok := field.Tag.Lookup("bson"); ok {
+			if tag == "-" {
+				continue
+			}
+			a := strings.Split(tag, ",")
+			if len(a[0]) > 0 {
+				name = a[0]
+			}
+			for _, x := range a[1:] {
+				if x == "inline" {
+					inline = true
+				}
+			}
+		}
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if inline && fieldType.Kind() == reflect.Struct {
+			if !visited[fieldType] {
+				collectIndexes(fieldType, prefix, specs, groups, seq, visited)
+			}
+			continue
+		}
+		if tag, ok := field.Tag.Lookup("index"); ok {
+			for _, def := range strings.Split(tag, ";") {
+				*seq++
+				addIndex(prefix+name, strings.TrimSpace(def), *seq, specs, groups)
+			}
+		}
+		if IsNestedStruct(fieldType) && !visited[fieldType] {
+			collectIndexes(fieldType, prefix+name+".", specs, groups, seq, visited)
+		}
+	}
+}
+func addIndex(fieldName string, def string, seq int, specs *[]*indexSpec, groups map[string]*indexSpec) {
+	f := indexField{name: fieldName, value: 1, seq: seq}
+	s := &indexSpec{}
+	for _, opt := range strings.Split(def, ",") {
+		kv := strings.SplitN(strings.TrimSpace(opt), "=", 2)
+		value := ""
+		if len(kv) == 2 {
+			value = strings.TrimSpace(kv[1])
+		}
+		switch kv[0] {
+		case "unique":
+			s.unique = true
+		case "sparse":
+			s.sparse = true
+		case "desc":
+			f.value = -1
+		case "text", "2dsphere", "2d", "hashed":
+			f.value = kv[0]
+		case "partial":
+			s.partial = bson.M{fieldName: bson.M{"$exists": true}}
+		case "name":
+			s.name = value
+		case "order":
+			f.order, _ = strconv.Atoi(value)
+		case "expireAfter":
+			if n, err := strconv.Atoi(value); err == nil {
+				s.expireAfter = int32(n)
+				s.hasTTL = true
+			} else if d, err := time.ParseDuration(value); err == nil {
+				s.expireAfter = int32(d / time.Second)
+				s.hasTTL = true
+			}
+		}
+	}
+	if len(s.name) > 0 {
+		if g, ok := groups[s.name]; ok {
+			g.fields = append(g.fields, f)
+			g.unique = g.unique || s.unique
+			g.sparse = g.sparse || s.sparse
+			if s.hasTTL {
+				g.expireAfter, g.hasTTL = s.expireAfter, true
+			}
+			if s.partial != nil {
+				if g.partial == nil {
+					g.partial = bson.M{}
+				}
+				for k, v := range s.partial {
+					g.partial[k] = v
+				}
+			}
+			return
+		}
+		groups[s.name] = s
+	}
+	s.fields = []indexField{f}
+	*specs = append(*specs, s)
+}
+func (s *indexSpec) keys() bson.D {
+	keys := bson.D{}
+	for _, f := range s.fields {
+		keys = append(keys, bson.E{Key: f.name, Value: f.value})
+	}
+	return keys
+}
+func (s *indexSpec) partialFilter(partial interface{}) interface{} {
+	if partial != nil {
+		return partial
+	}
+	if s.partial != nil {
+		return s.partial
+	}
+	return nil
+}
+func (s *indexSpec) model(partial interface{}) mongo.IndexModel {
+	opts := options.Index().SetName(s.name)
+	if s.unique {
+		opts.SetUnique(true)
+	}
+	if s.sparse {
+		opts.SetSparse(true)
+	}
+	if s.hasTTL {
+		opts.SetExpireAfterSeconds(s.expireAfter)
+	}
+	if p := s.partialFilter(partial); p != nil {
+		opts.SetPartialFilterExpression(p)
+	}
+	return mongo.IndexModel{Keys: s.keys(), Options: opts}
+}
+
+// equal compares the index with the existing index of the same name
+func (s *indexSpec) equal(idx bson.M, partial interface{}) bool {
+	key, _ := idx["key"].(bson.D)
+	if key == nil {
+		return false
+	}
+	isText := false
+	for _, e := range key {
+		if e.Key == "_fts" {
+			isText = true
+		}
+	}
+	if isText {
+		// the text fields are replaced by _fts and _ftsx, so only the order of the other fields is compared
+		others := make([]indexField, 0)
+		for _, f := range s.fields {
+			if f.value != "text" {
+				others = append(others, f)
+			}
+		}
+		i := 0
+		for _, e := range key {
+			if e.Key == "_fts" || e.Key == "_ftsx" {
+				continue
+			}
+			if i >= len(others) || e.Key != others[i].name || !sameValue(e.Value, others[i].value) {
+				return false
+			}
+			i++
+		}
+		if i != len(others) {
+			return false
+		}
+	} else {
+		if len(key) != len(s.fields) {
+			return false
+		}
+		for i, f := range s.fields {
+			if key[i].Key != f.name || !sameValue(key[i].Value, f.value) {
+				return false
+			}
+		}
+	}
+	unique, _ := idx["unique"].(bool)
+	sparse, _ := idx["sparse"].(bool)
+	if unique != s.unique || sparse != s.sparse {
+		return false
+	}
+	ttl, hasTTL := idx["expireAfterSeconds"]
+	if hasTTL != s.hasTTL || hasTTL && !sameValue(ttl, s.expireAfter) {
+		return false
+	}
+	p := s.partialFilter(partial)
+	current, hasPartial := idx["partialFilterExpression"]
+	if hasPartial != (p != nil) {
+		return false
+	}
+	return !hasPartial || sameDocument(current, p)
+}
+func sameValue(v1 interface{}, v2 interface{}) bool {
+	return fmt.Sprintf("%v", v1) == fmt.Sprintf("%v", v2)
+}
+func sameDocument(d1 interface{}, d2 interface{}) bool {
+	var m1, m2 bson.M
+	b1, err := bson.Marshal(d1)
+	if err != nil || bson.Unmarshal(b1, &m1) != nil {
+		return false
+	}
+	b2, err := bson.Marshal(d2)
+	if err != nil || bson.Unmarshal(b2, &m2) != nil {
+		return false
+	}
+	return reflect.DeepEqual(m1, m2)
+}
@@ -2,7 +2,7 @@ package mongo
 
 import (
 	"context"
-	"fmt"
+	"errors"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -11,7 +11,7 @@ import (
 func FindOne(ctx context.Context, collection *mongo.Collection, query bson.M, result interface{}) (bool, error) {
 	x := collection.FindOne(ctx, query)
 	if x.Err() != nil {
-		if fmt.Sprint(x.Err()) == "mongo: no documents in result" {
+		if errors.Is(x.Err(), mongo.ErrNoDocuments) {
 			return false, nil
 		}
 		return false, x.Err()
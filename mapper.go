@@ -0,0 +1,26 @@
+package mongo
+
+// ErrorMapper is implemented by the mappers which can fail, such as encrypt.FieldMapper if the key provider fails.
+// ModelToDb and MapToDb return the error of these mappers, instead of calling the methods of the Mapper, which panic.
+type ErrorMapper[T any] interface {
+	TryModelToDb(*T) error
+	TryMapToDb(map[string]interface{}) (map[string]interface{}, error)
+}
+
+func ModelToDb[T any](mapper interface{ ModelToDb(*T) }, model *T) error {
+	if m, ok := mapper.(ErrorMapper[T]); ok {
+		return m.TryModelToDb(model)
+	}
+	mapper.ModelToDb(model)
+	return nil
+}
+func MapToDb(mapper interface {
+	MapToDb(map[string]interface{}) map[string]interface{}
+}, m map[string]interface{}) (map[string]interface{}, error) {
+	if x, ok := mapper.(interface {
+		TryMapToDb(map[string]interface{}) (map[string]interface{}, error)
+	}); ok {
+		return x.TryMapToDb(m)
+	}
+	return mapper.MapToDb(m), nil
+}
@@ -0,0 +1,483 @@
+package memory
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Match evaluates the filter, such as the bson.D of query.Build, on the document. The document and the filter are normalized by bson, so that bson.M, bson.D and structs can be used.
+// These operators are supported: $eq, $ne, $in, $nin, $gt, $gte, $lt, $lte, $regex, $options, $exists, $all, $elemMatch, $size, $or, $and and $nor.
+func Match(doc interface{}, filter interface{}) (bool, error) {
+	d, err := toM(doc)
+	if err != nil {
+		return false, err
+	}
+	f, err := toD(filter)
+	if err != nil {
+		return false, err
+	}
+	return matchDoc(d, f)
+}
+
+// Filter returns the documents which match the filter
+func Filter(docs []bson.M, filter interface{}) ([]bson.M, error) {
+	f, err := toD(filter)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]bson.M, 0)
+	for _, doc := range docs {
+		ok, err := matchDoc(doc, f)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			res = append(res, doc)
+		}
+	}
+	return res, nil
+}
+
+// Sort sorts the documents by the sort of BuildSort, such as bson.D{{Key: "name", Value: 1}}, in the same order of the types as MongoDB
+func Sort(docs []bson.M, s bson.D) {
+	if len(s) == 0 {
+		return
+	}
+	sort.SliceStable(docs, func(i, j int) bool {
+		for _, e := range s {
+			c := compareValues(first(lookup(docs[i], strings.Split(e.Key, "."))), first(lookup(docs[j], strings.Split(e.Key, "."))))
+			if c == 0 {
+				continue
+			}
+			if toFloat(e.Value) < 0 {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+}
+
+func toM(v interface{}) (bson.M, error) {
+	if m, ok := v.(bson.M); ok {
+		return m, nil
+	}
+	b, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m bson.M
+	err = bson.Unmarshal(b, &m)
+	return m, err
+}
+func toD(v interface{}) (bson.D, error) {
+	if v == nil {
+		return bson.D{}, nil
+	}
+	b, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var d bson.D
+	err = bson.Unmarshal(b, &d)
+	return d, err
+}
+
+func matchDoc(doc bson.M, filter bson.D) (bool, error) {
+	for _, e := range filter {
+		ok, err := matchElement(doc, e)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+func matchElement(doc bson.M, e bson.E) (bool, error) {
+	switch e.Key {
+	case "$and", "$or", "$nor":
+		arr, ok := e.Value.(primitive.A)
+		if !ok {
+			return false, fmt.Errorf("%s must be an array", e.Key)
+		}
+		for _, x := range arr {
+			sub, ok := x.(bson.D)
+			if !ok {
+				return false, fmt.Errorf("%s must be an array of documents", e.Key)
+			}
+			matched, err := matchDoc(doc, sub)
+			if err != nil {
+				return false, err
+			}
+			if e.Key == "$and" && !matched {
+				return false, nil
+			}
+			if e.Key == "$or" && matched {
+				return true, nil
+			}
+			if e.Key == "$nor" && matched {
+				return false, nil
+			}
+		}
+		return e.Key != "$or", nil
+	}
+	if strings.HasPrefix(e.Key, "$") {
+		return false, fmt.Errorf("operator %s is not supported", e.Key)
+	}
+	values := lookup(doc, strings.Split(e.Key, "."))
+	if ops, ok := e.Value.(bson.D); ok && len(ops) > 0 && strings.HasPrefix(ops[0].Key, "$") {
+		return matchOperators(values, ops)
+	}
+	return matchEq(values, e.Value), nil
+}
+func matchOperators(values []interface{}, ops bson.D) (bool, error) {
+	for _, op := range ops {
+		var ok bool
+		switch op.Key {
+		case "$eq":
+			ok = matchEq(values, op.Value)
+		case "$ne":
+			ok = !matchEq(values, op.Value)
+		case "$exists":
+			ok = (len(values) > 0) == truthy(op.Value)
+		case "$size":
+			for _, v := range values {
+				if arr, isArr := v.(primitive.A); isArr && isNumber(op.Value) && float64(len(arr)) == toFloat(op.Value) {
+					ok = true
+				}
+			}
+		case "$all":
+			arr, isArr := op.Value.(primitive.A)
+			if !isArr {
+				return false, fmt.Errorf("%s must be an array", op.Key)
+			}
+			ok = len(arr) > 0
+			for _, x := range arr {
+				if !matchEq(values, x) {
+					ok = false
+					break
+				}
+			}
+		case "$elemMatch":
+			sub, isDoc := op.Value.(bson.D)
+			if !isDoc {
+				return false, fmt.Errorf("%s must be a document", op.Key)
+			}
+			matched, err := matchElements(values, sub)
+			if err != nil {
+				return false, err
+			}
+			ok = matched
+		case "$in", "$nin":
+			arr, isArr := op.Value.(primitive.A)
+			if !isArr {
+				return false, fmt.Errorf("%s must be an array", op.Key)
+			}
+			for _, x := range arr {
+				if matchEq(values, x) {
+					ok = true
+					break
+				}
+			}
+			if op.Key == "$nin" {
+				ok = !ok
+			}
+		case "$gt", "$gte", "$lt", "$lte":
+			ok = matchAny(values, func(v interface{}) bool {
+				c, comparable := compare(v, op.Value)
+				if !comparable {
+					return false
+				}
+				switch op.Key {
+				case "$gt":
+					return c > 0
+				case "$gte":
+					return c >= 0
+				case "$lt":
+					return c < 0
+				default:
+					return c <= 0
+				}
+			})
+		case "$regex":
+			re, err := toRegexp(op.Value, ops)
+			if err != nil {
+				return false, err
+			}
+			ok = matchAny(values, func(v interface{}) bool {
+				s, isString := v.(string)
+				return isString && re.MatchString(s)
+			})
+		case "$options":
+			continue
+		default:
+			return false, fmt.Errorf("operator %s is not supported", op.Key)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// matchEq matches the missing field with nil, and the arrays with the value or one of their elements
+func matchEq(values []interface{}, x interface{}) bool {
+	if x == nil && len(values) == 0 {
+		return true
+	}
+	if r, ok := x.(primitive.Regex); ok {
+		re, err := toRegexp(r, nil)
+		if err != nil {
+			return false
+		}
+		return matchAny(values, func(v interface{}) bool {
+			s, isString := v.(string)
+			return isString && re.MatchString(s)
+		})
+	}
+	return matchAny(values, func(v interface{}) bool {
+		return equal(v, x)
+	})
+}
+
+// matchElements returns true if an element of the arrays matches the filter, which is a query of the documents or operators of the values
+func matchElements(values []interface{}, filter bson.D) (bool, error) {
+	isOperator := len(filter) > 0 && strings.HasPrefix(filter[0].Key, "$")
+	for _, v := range values {
+		arr, ok := v.(primitive.A)
+		if !ok {
+			continue
+		}
+		for _, x := range arr {
+			var matched bool
+			var err error
+			if isOperator {
+				matched, err = matchOperators([]interface{}{x}, filter)
+			} else if doc, isDoc := x.(bson.M); isDoc {
+				matched, err = matchDoc(doc, filter)
+			}
+			if err != nil {
+				return false, err
+			}
+			if matched {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+func truthy(v interface{}) bool {
+	switch b := v.(type) {
+	case bool:
+		return b
+	case nil:
+		return false
+	}
+	return !isNumber(v) || toFloat(v) != 0
+}
+func matchAny(values []interface{}, f func(interface{}) bool) bool {
+	for _, v := range values {
+		if f(v) {
+			return true
+		}
+		if arr, ok := v.(primitive.A); ok {
+			for _, x := range arr {
+				if f(x) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+func toRegexp(v interface{}, ops bson.D) (*regexp.Regexp, error) {
+	var pattern, options string
+	switch r := v.(type) {
+	case primitive.Regex:
+		pattern, options = r.Pattern, r.Options
+	case string:
+		pattern = r
+	default:
+		return nil, fmt.Errorf("$regex must be a string or a regular expression")
+	}
+	for _, op := range ops {
+		if op.Key == "$options" {
+			options, _ = op.Value.(string)
+		}
+	}
+	flags := ""
+	for _, c := range options {
+		if c == 'i' || c == 'm' || c == 's' {
+			flags += string(c)
+		}
+	}
+	if len(flags) > 0 {
+		pattern = "(?" + flags + ")" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+// lookup returns the values of the path. The arrays are traversed, such as "items.name" of an array of items, or indexed, such as "items.0".
+func lookup(v interface{}, path []string) []interface{} {
+	if len(path) == 0 {
+		return []interface{}{v}
+	}
+	switch d := v.(type) {
+	case bson.M:
+		if x, ok := d[path[0]]; ok {
+			return lookup(x, path[1:])
+		}
+	case bson.D:
+		for _, e := range d {
+			if e.Key == path[0] {
+				return lookup(e.Value, path[1:])
+			}
+		}
+	case primitive.A:
+		if i, err := strconv.Atoi(path[0]); err == nil {
+			if i >= 0 && i < len(d) {
+				return lookup(d[i], path[1:])
+			}
+			return nil
+		}
+		values := make([]interface{}, 0)
+		for _, x := range d {
+			values = append(values, lookup(x, path)...)
+		}
+		return values
+	}
+	return nil
+}
+func first(values []interface{}) interface{} {
+	if len(values) == 0 {
+		return nil
+	}
+	return values[0]
+}
+
+func isNumber(v interface{}) bool {
+	switch v.(type) {
+	case int32, int64, float64, int, float32:
+		return true
+	}
+	return false
+}
+func toFloat(v interface{}) float64 {
+	switch n := v.(type) {
+	case int32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case int:
+		return float64(n)
+	case float32:
+		return float64(n)
+	case float64:
+		return n
+	}
+	return 0
+}
+func equal(v interface{}, x interface{}) bool {
+	if isNumber(v) && isNumber(x) {
+		return toFloat(v) == toFloat(x)
+	}
+	if c, ok := compare(v, x); ok {
+		return c == 0
+	}
+	return reflect.DeepEqual(v, x)
+}
+
+// compare compares the values of the same type, such as numbers, strings, dates, ObjectIDs and booleans
+func compare(v interface{}, x interface{}) (int, bool) {
+	if isNumber(v) && isNumber(x) {
+		a, b := toFloat(v), toFloat(x)
+		if a < b {
+			return -1, true
+		} else if a > b {
+			return 1, true
+		}
+		return 0, true
+	}
+	switch a := v.(type) {
+	case string:
+		if b, ok := x.(string); ok {
+			return strings.Compare(a, b), true
+		}
+	case primitive.DateTime:
+		if b, ok := x.(primitive.DateTime); ok {
+			return compareInt(int64(a), int64(b)), true
+		}
+		if b, ok := x.(time.Time); ok {
+			return compareInt(int64(a), b.UnixMilli()), true
+		}
+	case primitive.ObjectID:
+		if b, ok := x.(primitive.ObjectID); ok {
+			return strings.Compare(a.Hex(), b.Hex()), true
+		}
+	case bool:
+		if b, ok := x.(bool); ok {
+			if a == b {
+				return 0, true
+			}
+			if !a {
+				return -1, true
+			}
+			return 1, true
+		}
+	}
+	return 0, false
+}
+func compareInt(a int64, b int64) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+// rank is the order of the types in the sort of MongoDB
+func rank(v interface{}) int {
+	switch v.(type) {
+	case nil, primitive.Null, primitive.Undefined:
+		return 1
+	case int32, int64, float64, int, float32, primitive.Decimal128:
+		return 2
+	case string, primitive.Symbol:
+		return 3
+	case bson.M, bson.D:
+		return 4
+	case primitive.A:
+		return 5
+	case primitive.Binary:
+		return 6
+	case primitive.ObjectID:
+		return 7
+	case bool:
+		return 8
+	case primitive.DateTime, time.Time:
+		return 9
+	case primitive.Timestamp:
+		return 10
+	case primitive.Regex:
+		return 11
+	}
+	return 12
+}
+func compareValues(v interface{}, x interface{}) int {
+	r1, r2 := rank(v), rank(x)
+	if r1 != r2 {
+		return compareInt(int64(r1), int64(r2))
+	}
+	if c, ok := compare(v, x); ok {
+		return c
+	}
+	return 0
+}
@@ -0,0 +1,95 @@
+package memory
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMatch(t *testing.T) {
+	doc := bson.M{
+		"_id":    "1",
+		"name":   "Alice",
+		"age":    30,
+		"tags":   bson.A{"a", "b"},
+		"items":  bson.A{bson.M{"sku": "x", "qty": 2}, bson.M{"sku": "y", "qty": 5}},
+		"a":      bson.M{"b": "c"},
+		"remark": nil,
+	}
+	tests := []struct {
+		name   string
+		filter bson.D
+		want   bool
+	}{
+		{"empty", bson.D{}, true},
+		{"eq", bson.D{{Key: "name", Value: "Alice"}}, true},
+		{"eq not", bson.D{{Key: "name", Value: "Bob"}}, false},
+		{"eq numbers of other types", bson.D{{Key: "age", Value: int64(30)}}, true},
+		{"eq array element", bson.D{{Key: "tags", Value: "b"}}, true},
+		{"nested path", bson.D{{Key: "a.b", Value: "c"}}, true},
+		{"null matches null", bson.D{{Key: "remark", Value: nil}}, true},
+		{"null matches missing", bson.D{{Key: "missing", Value: nil}}, true},
+		{"ne", bson.D{{Key: "name", Value: bson.D{{Key: "$ne", Value: "Bob"}}}}, true},
+		{"gt", bson.D{{Key: "age", Value: bson.D{{Key: "$gt", Value: 29}}}}, true},
+		{"gte and lt", bson.D{{Key: "age", Value: bson.D{{Key: "$gte", Value: 30}, {Key: "$lt", Value: 30}}}}, false},
+		{"gt missing", bson.D{{Key: "missing", Value: bson.D{{Key: "$gt", Value: 0}}}}, false},
+		{"in", bson.D{{Key: "name", Value: bson.D{{Key: "$in", Value: bson.A{"Bob", "Alice"}}}}}, true},
+		{"nin", bson.D{{Key: "tags", Value: bson.D{{Key: "$nin", Value: bson.A{"a"}}}}}, false},
+		{"exists", bson.D{{Key: "remark", Value: bson.D{{Key: "$exists", Value: true}}}}, true},
+		{"not exists", bson.D{{Key: "missing", Value: bson.D{{Key: "$exists", Value: false}}}}, true},
+		{"regex", bson.D{{Key: "name", Value: bson.D{{Key: "$regex", Value: "^ali"}, {Key: "$options", Value: "i"}}}}, true},
+		{"regex case", bson.D{{Key: "name", Value: bson.D{{Key: "$regex", Value: "^ali"}}}}, false},
+		{"all", bson.D{{Key: "tags", Value: bson.D{{Key: "$all", Value: bson.A{"a", "b"}}}}}, true},
+		{"size", bson.D{{Key: "tags", Value: bson.D{{Key: "$size", Value: 3}}}}, false},
+		{"elemMatch", bson.D{{Key: "items", Value: bson.D{{Key: "$elemMatch", Value: bson.D{{Key: "sku", Value: "x"}, {Key: "qty", Value: bson.D{{Key: "$gt", Value: 1}}}}}}}}, true},
+		{"elemMatch of other elements", bson.D{{Key: "items", Value: bson.D{{Key: "$elemMatch", Value: bson.D{{Key: "sku", Value: "x"}, {Key: "qty", Value: 5}}}}}}, false},
+		{"or", bson.D{{Key: "$or", Value: bson.A{bson.D{{Key: "name", Value: "Bob"}}, bson.D{{Key: "age", Value: 30}}}}}, true},
+		{"and", bson.D{{Key: "$and", Value: bson.A{bson.D{{Key: "name", Value: "Alice"}}, bson.D{{Key: "age", Value: 31}}}}}, false},
+		{"nor", bson.D{{Key: "$nor", Value: bson.A{bson.D{{Key: "name", Value: "Bob"}}}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Match(doc, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSort(t *testing.T) {
+	tests := []struct {
+		name string
+		sort bson.D
+		want []string
+	}{
+		{"asc, missing first", bson.D{{Key: "age", Value: 1}}, []string{"4", "3", "2", "1"}},
+		{"desc", bson.D{{Key: "age", Value: -1}}, []string{"1", "2", "3", "4"}},
+		{"two fields", bson.D{{Key: "group", Value: 1}, {Key: "age", Value: -1}}, []string{"2", "3", "1", "4"}},
+		{"nested", bson.D{{Key: "a.b", Value: 1}}, []string{"4", "3", "2", "1"}},
+		{"no sort", bson.D{}, []string{"1", "2", "3", "4"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			docs := []bson.M{
+				{"_id": "1", "age": 40, "group": "b", "a": bson.M{"b": 4}},
+				{"_id": "2", "age": int64(30), "group": "a", "a": bson.M{"b": 3}},
+				{"_id": "3", "age": 20.5, "group": "a", "a": bson.M{"b": 2}},
+				{"_id": "4", "group": "b"},
+			}
+			Sort(docs, tt.sort)
+			got := make([]string, 0, len(docs))
+			for _, d := range docs {
+				got = append(got, d["_id"].(string))
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("Sort() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
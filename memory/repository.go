@@ -0,0 +1,413 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	mgo "github.com/core-go/mongo"
+	"github.com/core-go/mongo/repository"
+)
+
+// Repository is the in-memory implementation of repository.Repository for unit tests. The models are stored as bson documents, so that the bson tags, the ids and the versions work as in MongoDB.
+type Repository[T any, K any] struct {
+	Map          map[string]string
+	idIndex      int
+	idJson       string
+	versionIndex int
+	versionJson  string
+	versionBson  string
+	// ReturnError makes the methods return ErrNotFound, ErrDuplicateKey and ErrVersionConflict, instead of nil, 0 and -1
+	ReturnError bool
+	idStrategy  string
+	mu          sync.RWMutex
+	docs        map[string]bson.M
+	keys        []string
+}
+
+func NewRepositoryWithVersion[T any, K any](versionField string) *Repository[T, K] {
+	var t T
+	modelType := reflect.TypeOf(t)
+	if modelType.Kind() != reflect.Struct {
+		panic("T must be a struct")
+	}
+	idIndex, _, jsonIdName := mgo.FindIdField(modelType)
+	repo := &Repository[T, K]{idIndex: idIndex, idJson: jsonIdName, versionIndex: -1, Map: mgo.MakeBsonMap(modelType), docs: make(map[string]bson.M)}
+	if len(versionField) > 0 {
+		index, versionJson, versionBson := repository.FindFieldByName(modelType, versionField)
+		if index >= 0 {
+			repo.versionIndex = index
+			repo.versionJson = versionJson
+			repo.versionBson = versionBson
+		}
+	}
+	return repo
+}
+func NewRepository[T any, K any]() *Repository[T, K] {
+	return NewRepositoryWithVersion[T, K]("")
+}
+
+// SetIdStrategy sets the strategy of the string ids, which are generated by Create. By default, they are ObjectID hex, as the ids generated by MongoDB.
+func (a *Repository[T, K]) SetIdStrategy(strategy string) {
+	a.idStrategy = strategy
+}
+
+// Reset deletes all documents
+func (a *Repository[T, K]) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.docs = make(map[string]bson.M)
+	a.keys = nil
+}
+func (a *Repository[T, K]) All(ctx context.Context) ([]T, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return toModels[T](a.list())
+}
+func (a *Repository[T, K]) Load(ctx context.Context, id K) (*T, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	doc, ok := a.docs[toKey(id)]
+	if !ok {
+		if a.ReturnError {
+			return nil, mgo.ErrNotFound
+		}
+		return nil, nil
+	}
+	var model T
+	if err := decode(doc, &model); err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
+// LoadMany returns the models of the ids in the order of the ids, and the ids which are not found
+func (a *Repository[T, K]) LoadMany(ctx context.Context, ids []K) ([]T, []K, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	objs := make([]T, 0, len(ids))
+	missing := make([]K, 0)
+	for _, id := range ids {
+		doc, ok := a.docs[toKey(id)]
+		if !ok {
+			missing = append(missing, id)
+			continue
+		}
+		var model T
+		if err := decode(doc, &model); err != nil {
+			return nil, nil, err
+		}
+		objs = append(objs, model)
+	}
+	return objs, missing, nil
+}
+func (a *Repository[T, K]) Exist(ctx context.Context, id K) (bool, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	_, ok := a.docs[toKey(id)]
+	return ok, nil
+}
+func (a *Repository[T, K]) Create(ctx context.Context, model *T) (int64, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	vo := reflect.Indirect(reflect.ValueOf(model))
+	if a.versionIndex >= 0 {
+		if v, ok := mgo.NewVersion(vo.Field(a.versionIndex).Type()); ok {
+			vo.Field(a.versionIndex).Set(reflect.ValueOf(v))
+		}
+	}
+	a.generateId(vo)
+	return a.insert(model)
+}
+func (a *Repository[T, K]) Update(ctx context.Context, model *T) (int64, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	vo := reflect.Indirect(reflect.ValueOf(model))
+	key, err := a.modelKey(vo)
+	if err != nil {
+		return 0, err
+	}
+	current, ok := a.docs[key]
+	if !ok {
+		return a.notFound()
+	}
+	if a.versionIndex >= 0 {
+		if res, err := a.checkVersion(current, vo.Field(a.versionIndex).Interface()); res < 0 || err != nil {
+			return res, err
+		}
+		a.increaseVersion(vo)
+	}
+	return a.set(key, current, model)
+}
+
+// Patch sets the fields of the map, which has json names. The nested maps of the nested structs only update their fields, as Patch of repository.Repository.
+func (a *Repository[T, K]) Patch(ctx context.Context, model map[string]interface{}) (int64, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	id, ok := model[a.idJson]
+	if !ok || id == nil {
+		return -1, fmt.Errorf("%s must be in model for patch", a.idJson)
+	}
+	key := toKey(id)
+	current, ok := a.docs[key]
+	if !ok {
+		return a.notFound()
+	}
+	set := mgo.MapToBson(model, a.Map)
+	if a.versionIndex >= 0 {
+		currentVersion, vok := model[a.versionJson]
+		if !vok {
+			return -1, fmt.Errorf("%s must be in model for patch", a.versionJson)
+		}
+		var t T
+		versionType := reflect.TypeOf(t).Field(a.versionIndex).Type
+		currentVersion, vok = mgo.ToVersion(versionType, currentVersion)
+		if !vok {
+			return -1, errors.New("do not support this version type")
+		}
+		if res, err := a.checkVersion(current, currentVersion); res < 0 || err != nil {
+			return res, err
+		}
+		next, _ := mgo.NextVersion(versionType, currentVersion)
+		set[a.versionBson] = next
+		model[a.versionJson] = next
+	}
+	delete(set, "_id")
+	doc, err := clone(current)
+	if err != nil {
+		return 0, err
+	}
+	for k, v := range set {
+		value, err := toValue(v)
+		if err != nil {
+			return 0, err
+		}
+		setPath(doc, k, value)
+	}
+	var check T
+	if err = decode(doc, &check); err != nil {
+		return 0, err
+	}
+	a.docs[key] = doc
+	return 1, nil
+}
+
+// Save inserts the model if the id is empty or not found, and sets the fields of the model to the document otherwise
+func (a *Repository[T, K]) Save(ctx context.Context, model *T) (int64, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	vo := reflect.Indirect(reflect.ValueOf(model))
+	if a.idIndex >= 0 && !mgo.IsCompositeId(vo.Field(a.idIndex).Type()) && mgo.IsEmptyId(vo.Field(a.idIndex).Interface()) {
+		if a.versionIndex >= 0 {
+			if v, ok := mgo.NewVersion(vo.Field(a.versionIndex).Type()); ok {
+				vo.Field(a.versionIndex).Set(reflect.ValueOf(v))
+			}
+		}
+		a.generateId(vo)
+		return a.insert(model)
+	}
+	key, err := a.modelKey(vo)
+	if err != nil {
+		return 0, err
+	}
+	if a.versionIndex >= 0 {
+		if current, ok := a.docs[key]; ok {
+			if res, err := a.checkVersion(current, vo.Field(a.versionIndex).Interface()); res < 0 || err != nil {
+				return res, err
+			}
+		}
+		a.increaseVersion(vo)
+	}
+	current, ok := a.docs[key]
+	if !ok {
+		current = bson.M{}
+		a.keys = append(a.keys, key)
+	}
+	return a.set(key, current, model)
+}
+func (a *Repository[T, K]) Delete(ctx context.Context, id K) (int64, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	key := toKey(id)
+	if _, ok := a.docs[key]; !ok {
+		return a.notFound()
+	}
+	delete(a.docs, key)
+	for i, k := range a.keys {
+		if k == key {
+			a.keys = append(a.keys[:i], a.keys[i+1:]...)
+			break
+		}
+	}
+	return 1, nil
+}
+
+// GetVersion returns the version of the model, which can be sent in ETag http header by mgo.ETag
+func (a *Repository[T, K]) GetVersion(model *T) (interface{}, bool) {
+	if a.versionIndex < 0 || model == nil {
+		return nil, false
+	}
+	return reflect.ValueOf(model).Elem().Field(a.versionIndex).Interface(), true
+}
+
+// list returns the documents in the insertion order, as the natural order of MongoDB
+func (a *Repository[T, K]) list() []bson.M {
+	docs := make([]bson.M, 0, len(a.keys))
+	for _, k := range a.keys {
+		docs = append(docs, a.docs[k])
+	}
+	return docs
+}
+
+// set sets the fields of the model to the document, as $set of repository.Repository: the omitempty fields of the model and the fields of the document, which are not in T, are kept
+func (a *Repository[T, K]) set(key string, current bson.M, model *T) (int64, error) {
+	fields, err := toM(model)
+	if err != nil {
+		return 0, err
+	}
+	doc, err := clone(current)
+	if err != nil {
+		return 0, err
+	}
+	for k, v := range fields {
+		doc[k] = v
+	}
+	a.docs[key] = doc
+	return 1, nil
+}
+func (a *Repository[T, K]) insert(model *T) (int64, error) {
+	doc, err := toM(model)
+	if err != nil {
+		return 0, err
+	}
+	key := toKey(doc["_id"])
+	if _, ok := a.docs[key]; ok {
+		if a.ReturnError {
+			return 0, &mgo.DuplicateKeyError{Index: "_id_", Keys: map[string]interface{}{"_id": doc["_id"]}}
+		}
+		return 0, nil
+	}
+	a.docs[key] = doc
+	a.keys = append(a.keys, key)
+	return 1, nil
+}
+
+// generateId generates the empty id as MongoDB: ObjectID and UUID ids by the id type, and the string ids as ObjectID hex, or by the id strategy
+func (a *Repository[T, K]) generateId(vo reflect.Value) {
+	if a.idIndex < 0 {
+		return
+	}
+	f := vo.Field(a.idIndex)
+	if mgo.IsCompositeId(f.Type()) || !mgo.IsEmptyId(f.Interface()) {
+		return
+	}
+	strategy := a.idStrategy
+	if strategy == mgo.IdDefault {
+		strategy = mgo.IdObjectId
+	}
+	if id, ok := mgo.NewId(f.Type(), strategy); ok {
+		f.Set(reflect.ValueOf(id))
+	}
+}
+func (a *Repository[T, K]) modelKey(vo reflect.Value) (string, error) {
+	if a.idIndex < 0 {
+		return "", errors.New("T must have a field of _id bson tag")
+	}
+	doc, err := toM(vo.Addr().Interface())
+	if err != nil {
+		return "", err
+	}
+	return toKey(doc["_id"]), nil
+}
+
+// checkVersion returns -1 if the version of the document is not the version of the model
+func (a *Repository[T, K]) checkVersion(doc bson.M, version interface{}) (int64, error) {
+	v, err := toValue(version)
+	if err != nil {
+		return 0, err
+	}
+	if equal(doc[a.versionBson], v) {
+		return 1, nil
+	}
+	if a.ReturnError {
+		return 0, mgo.ErrVersionConflict
+	}
+	return -1, nil
+}
+func (a *Repository[T, K]) increaseVersion(vo reflect.Value) {
+	f := vo.Field(a.versionIndex)
+	if v, ok := mgo.NextVersion(f.Type(), f.Interface()); ok {
+		f.Set(reflect.ValueOf(v))
+	}
+}
+func (a *Repository[T, K]) notFound() (int64, error) {
+	if a.ReturnError {
+		return 0, mgo.ErrNotFound
+	}
+	return 0, nil
+}
+
+func decode(doc bson.M, result interface{}) error {
+	b, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(b, result)
+}
+func toModels[T any](docs []bson.M) ([]T, error) {
+	objs := make([]T, 0, len(docs))
+	for _, doc := range docs {
+		var model T
+		if err := decode(doc, &model); err != nil {
+			return nil, err
+		}
+		objs = append(objs, model)
+	}
+	return objs, nil
+}
+func clone(doc bson.M) (bson.M, error) {
+	var m bson.M
+	err := decode(doc, &m)
+	return m, err
+}
+
+// toValue converts the value to the value stored in the document, such as primitive.DateTime for time.Time
+func toValue(v interface{}) (interface{}, error) {
+	m, err := toM(bson.M{"v": v})
+	if err != nil {
+		return nil, err
+	}
+	return m["v"], nil
+}
+
+// toKey converts the id to the key of the document, so that the hex string of ObjectID, the UUID and the composite key match the stored _id
+func toKey(id interface{}) string {
+	v, err := toValue(id)
+	if err != nil {
+		return mgo.ToKey(id)
+	}
+	if b, ok := v.(primitive.Binary); ok && b.Subtype == bson.TypeBinaryUUID && len(b.Data) == 16 {
+		var u mgo.UUID
+		copy(u[:], b.Data)
+		return u.String()
+	}
+	return mgo.ToKey(v)
+}
+func setPath(doc bson.M, path string, value interface{}) {
+	keys := strings.Split(path, ".")
+	for _, k := range keys[:len(keys)-1] {
+		sub, ok := doc[k].(bson.M)
+		if !ok {
+			sub = bson.M{}
+			doc[k] = sub
+		}
+		doc = sub
+	}
+	doc[keys[len(keys)-1]] = value
+}
@@ -0,0 +1,53 @@
+package memory
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type user struct {
+	Id      string `bson:"_id" json:"id"`
+	Name    string `bson:"name" json:"name"`
+	Email   string `bson:"email,omitempty" json:"email,omitempty"`
+	Version int    `bson:"version" json:"version"`
+}
+
+func TestUpdateSetsFields(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRepositoryWithVersion[user, string]("Version")
+	u := user{Id: "1", Name: "a", Email: "a@x.com"}
+	if _, err := repo.Create(ctx, &u); err != nil {
+		t.Fatal(err)
+	}
+	// the field, which is not in T, is kept as $set does
+	repo.docs["1"]["legacy"] = "x"
+	tests := []struct {
+		name  string
+		write func(*user) (int64, error)
+	}{
+		{"update", func(u *user) (int64, error) { return repo.Update(ctx, u) }},
+		{"save", func(u *user) (int64, error) { return repo.Save(ctx, u) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current, _ := repo.Load(ctx, "1")
+			m := user{Id: "1", Name: "b", Version: current.Version}
+			res, err := tt.write(&m)
+			if err != nil || res != 1 {
+				t.Fatalf("res = %d, err = %v", res, err)
+			}
+			doc := repo.docs["1"]
+			want := bson.M{"name": "b", "email": "a@x.com", "legacy": "x"}
+			for k, v := range want {
+				if doc[k] != v {
+					t.Errorf("%s = %v, want %v", k, doc[k], v)
+				}
+			}
+			if doc["version"] != int32(current.Version+1) {
+				t.Errorf("version = %v, want %d", doc["version"], current.Version+1)
+			}
+		})
+	}
+}
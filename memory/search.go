@@ -0,0 +1,122 @@
+package memory
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	mgo "github.com/core-go/mongo"
+)
+
+// SearchRepository is the in-memory implementation of repository.SearchRepository. The filters of BuildQuery, such as query.Build, are evaluated by Match, and the sorts of BuildSort by Sort.
+type SearchRepository[T any, K any, F any] struct {
+	*Repository[T, K]
+	BuildQuery func(m F) (bson.D, bson.M)
+	GetSort    func(m interface{}) string
+	BuildSort  func(s string, modelType reflect.Type) bson.D
+	ModelType  reflect.Type
+}
+
+func NewSearchRepositoryWithVersion[T any, K any, F any](buildQuery func(m F) (bson.D, bson.M), getSort func(interface{}) string, versionField string, options ...func(string, reflect.Type) bson.D) *SearchRepository[T, K, F] {
+	repo := NewRepositoryWithVersion[T, K](versionField)
+	var t T
+	modelType := reflect.TypeOf(t)
+	buildSort := mgo.BuildSort
+	if len(options) > 0 && options[0] != nil {
+		buildSort = options[0]
+	}
+	return &SearchRepository[T, K, F]{Repository: repo, BuildSort: buildSort, GetSort: getSort, BuildQuery: buildQuery, ModelType: modelType}
+}
+func NewSearchRepository[T any, K any, F any](buildQuery func(m F) (bson.D, bson.M), getSort func(interface{}) string, options ...func(string, reflect.Type) bson.D) *SearchRepository[T, K, F] {
+	return NewSearchRepositoryWithVersion[T, K, F](buildQuery, getSort, "", options...)
+}
+func (b *SearchRepository[T, K, F]) Search(ctx context.Context, m F, limit int64, skip int64) ([]T, int64, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	docs, fields, err := b.find(m)
+	if err != nil {
+		return nil, 0, err
+	}
+	total := int64(len(docs))
+	if skip < 0 {
+		skip = 0
+	}
+	if skip > total {
+		skip = total
+	}
+	docs = docs[skip:]
+	if limit > 0 && limit < int64(len(docs)) {
+		docs = docs[:limit]
+	}
+	objs, err := toModels[T](project(docs, fields))
+	return objs, total, err
+}
+func (b *SearchRepository[T, K, F]) Count(ctx context.Context, m F) (int64, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	query, _ := b.BuildQuery(m)
+	docs, err := Filter(b.list(), query)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(docs)), nil
+}
+func (b *SearchRepository[T, K, F]) ExistBy(ctx context.Context, m F) (bool, error) {
+	total, err := b.Count(ctx, m)
+	return total > 0, err
+}
+
+// FindBy returns all models of the filter, in the sort of the filter
+func (b *SearchRepository[T, K, F]) FindBy(ctx context.Context, m F) ([]T, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	docs, fields, err := b.find(m)
+	if err != nil {
+		return nil, err
+	}
+	return toModels[T](project(docs, fields))
+}
+func (b *SearchRepository[T, K, F]) find(m F) ([]bson.M, bson.M, error) {
+	query, fields := b.BuildQuery(m)
+	docs, err := Filter(b.list(), query)
+	if err != nil {
+		return nil, nil, err
+	}
+	if b.GetSort != nil && b.BuildSort != nil {
+		Sort(docs, b.BuildSort(b.GetSort(m), b.ModelType))
+	}
+	return docs, fields, nil
+}
+
+// project applies the projection of the top level fields. The inclusion projection, such as the fields of query.Build, keeps _id unless it is excluded.
+func project(docs []bson.M, fields bson.M) []bson.M {
+	if len(fields) == 0 {
+		return docs
+	}
+	include := false
+	keep := map[string]bool{}
+	for k, v := range fields {
+		on := v == true || toFloat(v) != 0
+		keep[strings.Split(k, ".")[0]] = on
+		if on && k != "_id" {
+			include = true
+		}
+	}
+	if _, ok := keep["_id"]; !ok && include {
+		keep["_id"] = true
+	}
+	res := make([]bson.M, 0, len(docs))
+	for _, doc := range docs {
+		d := bson.M{}
+		for k, v := range doc {
+			on, ok := keep[k]
+			if include && on || !include && !ok {
+				d[k] = v
+			}
+		}
+		res = append(res, d)
+	}
+	return res
+}
@@ -0,0 +1,296 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	mgo "github.com/core-go/mongo"
+	"github.com/core-go/mongo/client"
+)
+
+const lockId = "lock"
+
+var (
+	ErrLocked         = errors.New("migrations are locked by another instance")
+	ErrLockLost       = errors.New("migration lock is taken by another instance")
+	ErrNoDown         = errors.New("migration does not have Down function")
+	ErrUnknownVersion = errors.New("unknown migration version")
+)
+
+type Migration struct {
+	Version     int64
+	Description string
+	Up          func(ctx context.Context, db *mongo.Database) error
+	Down        func(ctx context.Context, db *mongo.Database) error
+}
+
+// Record is the document of an applied migration in the migrations collection
+type Record struct {
+	Version     int64     `json:"version" bson:"_id"`
+	Description string    `json:"description,omitempty" bson:"description,omitempty"`
+	AppliedAt   time.Time `json:"appliedAt" bson:"appliedAt"`
+}
+
+// Migrator runs the registered migrations in the order of their versions, and records the applied versions in the migrations collection.
+// The migrations collection also has a lock document, so that only one instance migrates at a time. The lock expires after LockTimeout, if the instance stops while migrating.
+// While migrating, the lock is refreshed before each migration and every third of LockTimeout. If it is taken by another instance, the migrations stop with ErrLockLost.
+// If DryRun is true, Up, Down and MigrateTo only return the versions to run.
+type Migrator struct {
+	DB          *mongo.Database
+	Collection  *mongo.Collection
+	LockTimeout time.Duration
+	DryRun      bool
+	migrations  []Migration
+}
+
+// NewMigrator creates a Migrator, with the collection name "migrations" by default
+func NewMigrator(db *mongo.Database, options ...string) *Migrator {
+	name := "migrations"
+	if len(options) > 0 && len(options[0]) > 0 {
+		name = options[0]
+	}
+	return &Migrator{DB: db, Collection: db.Collection(name), LockTimeout: 10 * time.Minute}
+}
+
+// Register adds a migration. It panics if the version is already registered.
+func (m *Migrator) Register(version int64, description string, up func(ctx context.Context, db *mongo.Database) error, down func(ctx context.Context, db *mongo.Database) error) *Migrator {
+	return m.Add(Migration{Version: version, Description: description, Up: up, Down: down})
+}
+func (m *Migrator) Add(migrations ...Migration) *Migrator {
+	for _, x := range migrations {
+		if x.Up == nil {
+			panic(fmt.Sprintf("migration %d must have Up function", x.Version))
+		}
+		for _, y := range m.migrations {
+			if x.Version == y.Version {
+				panic(fmt.Sprintf("migration %d is already registered", x.Version))
+			}
+		}
+		m.migrations = append(m.migrations, x)
+	}
+	sort.Slice(m.migrations, func(i, j int) bool { return m.migrations[i].Version < m.migrations[j].Version })
+	return m
+}
+
+// Applied returns the applied migrations, in the order of their versions
+func (m *Migrator) Applied(ctx context.Context) ([]Record, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	cursor, err := m.Collection.Find(ctx, bson.M{"_id": bson.M{"$ne": lockId}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]Record, 0)
+	if err = cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// Version returns the latest applied version, or 0 if no migration is applied
+func (m *Migrator) Version(ctx context.Context) (int64, error) {
+	records, err := m.Applied(ctx)
+	if err != nil || len(records) == 0 {
+		return 0, err
+	}
+	return records[len(records)-1].Version, nil
+}
+
+// Up applies all pending migrations. The applied versions which are not registered, such as by a newer binary in a rolling deploy, are ignored.
+func (m *Migrator) Up(ctx context.Context) ([]int64, error) {
+	if len(m.migrations) == 0 {
+		return []int64{}, nil
+	}
+	return m.migrate(ctx, m.migrations[len(m.migrations)-1].Version, false)
+}
+
+// Down reverts the latest applied migration
+func (m *Migrator) Down(ctx context.Context) ([]int64, error) {
+	records, err := m.Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return []int64{}, nil
+	}
+	var target int64
+	if len(records) > 1 {
+		target = records[len(records)-2].Version
+	}
+	return m.MigrateTo(ctx, target)
+}
+
+// MigrateTo applies the pending migrations up to the version, and reverts the applied migrations after the version, in reverse order.
+// Version 0 reverts all migrations. It returns the versions which are applied or reverted.
+func (m *Migrator) MigrateTo(ctx context.Context, version int64) ([]int64, error) {
+	if version != 0 && m.find(version) == nil {
+		return nil, ErrUnknownVersion
+	}
+	return m.migrate(ctx, version, true)
+}
+
+// migrate applies the pending migrations up to the version, and reverts the applied migrations after the version if revert is true
+func (m *Migrator) migrate(ctx context.Context, version int64, revert bool) ([]int64, error) {
+	var owner string
+	var lost int32
+	if !m.DryRun {
+		var err error
+		owner, err = m.lock(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer m.unlock(owner)
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		go m.keepLock(ctx, cancel, owner, &lost)
+	}
+	done, err := m.migrateTo(ctx, owner, version, revert)
+	if err != nil && atomic.LoadInt32(&lost) == 1 {
+		return done, ErrLockLost
+	}
+	return done, err
+}
+func (m *Migrator) migrateTo(ctx context.Context, owner string, version int64, revert bool) ([]int64, error) {
+	records, err := m.Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[int64]bool)
+	for _, r := range records {
+		applied[r.Version] = true
+	}
+	done := make([]int64, 0)
+	for i := len(records) - 1; i >= 0 && revert; i-- {
+		v := records[i].Version
+		if v <= version {
+			break
+		}
+		x := m.find(v)
+		if x == nil {
+			return done, ErrUnknownVersion
+		}
+		if x.Down == nil {
+			return done, ErrNoDown
+		}
+		if !m.DryRun {
+			if err = m.refresh(ctx, owner); err != nil {
+				return done, err
+			}
+			if err = x.Down(ctx, m.DB); err != nil {
+				return done, err
+			}
+			if _, err = m.Collection.DeleteOne(ctx, bson.M{"_id": v}); err != nil {
+				return done, err
+			}
+		}
+		done = append(done, v)
+	}
+	for _, x := range m.migrations {
+		if x.Version > version {
+			break
+		}
+		if applied[x.Version] {
+			continue
+		}
+		if !m.DryRun {
+			if err = m.refresh(ctx, owner); err != nil {
+				return done, err
+			}
+			if err = x.Up(ctx, m.DB); err != nil {
+				return done, err
+			}
+			record := Record{Version: x.Version, Description: x.Description, AppliedAt: time.Now()}
+			if _, err = m.Collection.InsertOne(ctx, record); err != nil {
+				return done, err
+			}
+		}
+		done = append(done, x.Version)
+	}
+	return done, nil
+}
+func (m *Migrator) find(version int64) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+// lock upserts the lock document if it does not exist or it is expired. The upsert fails with a duplicate key if another instance holds the lock.
+func (m *Migrator) lock(ctx context.Context) (string, error) {
+	host, _ := os.Hostname()
+	owner := host + "-" + mgo.NewUUID()
+	now := time.Now()
+	filter := bson.M{"_id": lockId, "expiresAt": bson.M{"$lt": now}}
+	update := bson.M{"$set": bson.M{"owner": owner, "lockedAt": now, "expiresAt": now.Add(m.LockTimeout)}}
+	_, err := m.Collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return "", ErrLocked
+		}
+		return "", err
+	}
+	return owner, nil
+}
+
+// refresh extends the lock of the owner, or returns ErrLockLost if the lock is taken by another instance
+func (m *Migrator) refresh(ctx context.Context, owner string) error {
+	filter := bson.M{"_id": lockId, "owner": owner}
+	res, err := m.Collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"expiresAt": time.Now().Add(m.LockTimeout)}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// keepLock refreshes the lock during the long migrations, and cancels them if the lock is taken by another instance. The other errors are retried at the next refresh.
+func (m *Migrator) keepLock(ctx context.Context, cancel context.CancelFunc, owner string, lost *int32) {
+	interval := m.LockTimeout / 3
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.refresh(ctx, owner); err == ErrLockLost {
+				atomic.StoreInt32(lost, 1)
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+// unlock does not use the context of the migrations, which may be cancelled
+func (m *Migrator) unlock(owner string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, _ = m.Collection.DeleteOne(ctx, bson.M{"_id": lockId, "owner": owner})
+}
+
+// Migrate connects to the database by client.Setup, applies all pending migrations, and disconnects
+func Migrate(ctx context.Context, conf client.MongoConfig, migrations ...Migration) ([]int64, error) {
+	db, err := client.Setup(ctx, conf)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Client().Disconnect(ctx)
+	return NewMigrator(db).Add(migrations...).Up(ctx)
+}
@@ -3,6 +3,7 @@ package mongo
 import (
 	"context"
 	"errors"
+	"fmt"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -44,22 +45,26 @@ func difference(slice1 []string, slice2 []string) []string {
 }
 func FindByIds(ctx context.Context, collection *mongo.Collection, ids []string, result interface{}, idObjectId bool) ([]string, error) {
 	var keys []string
+	res := reflect.Indirect(reflect.ValueOf(result))
+	idIndex, _, _ := FindIdField(res.Type().Elem())
+	if idIndex < 0 {
+		idIndex = 0
+	}
 	if !idObjectId {
-		res := reflect.Indirect(reflect.ValueOf(result))
 		find, errFind := collection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
 		if errFind != nil {
 			return ids, errFind
 		}
-		find.All(ctx, result)
-		keySuccess := []string{""}
-		//		_, fieldName := FindIdField(modelType)
+		defer find.Close(ctx)
+		if err := find.All(ctx, result); err != nil {
+			return ids, err
+		}
+		keySuccess := make([]string, 0)
 		for i := 0; i < res.Len(); i++ {
-			keySuccess = append(keySuccess, res.Index(i).Field(0).String())
+			keySuccess = append(keySuccess, res.Index(i).Field(idIndex).String())
 		}
 		keys = difference(keySuccess, ids)
-		_ = find.Close(ctx)
 	} else {
-		res := reflect.Indirect(reflect.ValueOf(result))
 		id := make([]primitive.ObjectID, 0)
 		for _, val := range ids {
 			item, err := primitive.ObjectIDFromHex(val)
@@ -72,17 +77,13 @@ func FindByIds(ctx context.Context, collection *mongo.Collection, ids []string,
 		if err != nil {
 			return ids, err
 		}
-		find.All(ctx, result)
-		keySuccess := make([]primitive.ObjectID, 0)
-		//_, fieldName := FindIdField(modelType)
-		for i := 0; i < res.Len(); i++ {
-			key, _ := primitive.ObjectIDFromHex(res.Index(i).Field(0).Interface().(primitive.ObjectID).Hex())
-			keySuccess = append(keySuccess, key)
+		defer find.Close(ctx)
+		if err = find.All(ctx, result); err != nil {
+			return ids, err
 		}
-		keyToStr := []string{""}
-		for index, _ := range keySuccess {
-			key := keySuccess[index].Hex()
-			keyToStr = append(keyToStr, key)
+		keyToStr := make([]string, 0)
+		for i := 0; i < res.Len(); i++ {
+			keyToStr = append(keyToStr, ToKey(res.Index(i).Field(idIndex).Interface()))
 		}
 		keys = difference(keyToStr, ids)
 	}
@@ -91,3 +92,68 @@ func FindByIds(ctx context.Context, collection *mongo.Collection, ids []string,
 	}
 	return keys, errors.New("no result return")
 }
+
+// LoadMany loads the documents by ids in the order of ids, and returns the ids which are not found.
+// idIndex is the index of the field having _id bson tag. query is the additional filter, and can be nil.
+func LoadMany[T any, K any](ctx context.Context, collection *mongo.Collection, ids []K, idIndex int, idObjectId bool, query bson.M) ([]T, []K, error) {
+	if idIndex < 0 {
+		return nil, nil, errors.New("model must have a field with _id bson tag")
+	}
+	if len(ids) == 0 {
+		return make([]T, 0), make([]K, 0), nil
+	}
+	values := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		v, err := ToId(id, idObjectId)
+		if err != nil {
+			return nil, nil, err
+		}
+		values = append(values, v)
+	}
+	filter := bson.M{}
+	for k, v := range query {
+		filter[k] = v
+	}
+	filter["_id"] = bson.M{"$in": values}
+	cursor, err := collection.Find(ctx, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	var objs []T
+	if err = cursor.All(ctx, &objs); err != nil {
+		return nil, nil, err
+	}
+	found := make(map[string]int)
+	for i := range objs {
+		v := reflect.ValueOf(&objs[i]).Elem().Field(idIndex)
+		found[ToKey(v.Interface())] = i
+	}
+	results := make([]T, 0, len(objs))
+	missing := make([]K, 0)
+	for i, id := range ids {
+		if j, ok := found[ToKey(values[i])]; ok {
+			results = append(results, objs[j])
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	return results, missing, nil
+}
+
+// ToKey converts an id to string to compare ids of different types, such as primitive.ObjectID and hex string
+func ToKey(id interface{}) string {
+	v := reflect.ValueOf(id)
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return ""
+		}
+		id = v.Elem().Interface()
+	}
+	switch v := id.(type) {
+	case primitive.ObjectID:
+		return v.Hex()
+	case UUID:
+		return v.String()
+	}
+	return fmt.Sprintf("%v", id)
+}
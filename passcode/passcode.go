@@ -2,7 +2,7 @@ package passcode
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"strings"
 	"time"
 
@@ -57,7 +57,7 @@ func (p *PasscodeRepository) Load(ctx context.Context, id string) (string, time.
 	x := p.collection.FindOne(ctx, idQuery)
 	er1 := x.Err()
 	if er1 != nil {
-		if strings.Compare(fmt.Sprint(er1), "mongo: no documents in result") == 0 {
+		if errors.Is(er1, mongo.ErrNoDocuments) {
 			return "", time.Now().Add(-24 * time.Hour), nil
 		}
 		return "", time.Now().Add(-24 * time.Hour), er1
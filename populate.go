@@ -0,0 +1,194 @@
+package mongo
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Ref is a field tagged `ref:"collection"`, which holds the id or the ids of the documents of another collection.
+// The documents are populated into the target field, which is the field name without the "Id" or "Ids" suffix by default, such as CustomerId and Customer,
+// or the second part of the tag, such as `ref:"customers,Buyer"`. The next parts are the options: with "objectId", the string ids are converted to ObjectID,
+// and with "scoped", the referenced collection has the same tenant and soft delete fields as the collection of the model, such as `ref:"customers,,scoped"`.
+// The target field must be tagged `bson:"-"`, so that the populated documents are not written back to the document.
+type Ref struct {
+	Collection string
+	Index      int
+	Target     int
+	ObjectId   bool
+	Scoped     bool
+	// Scope adds the conditions of the referenced collection, such as tenant and soft delete, to the $in query. The query is not scoped if it is nil.
+	Scope func(ctx context.Context, filter bson.D) (bson.D, error)
+	// DbToModel is called with the pointer to each referenced document, such as by the mapper of the referenced collection
+	DbToModel func(model interface{})
+}
+
+func FindRefs(modelType reflect.Type) []Ref {
+	if modelType.Kind() == reflect.Ptr {
+		modelType = modelType.Elem()
+	}
+	refs := make([]Ref, 0)
+	numField := modelType.NumField()
+	for i := 0; i < numField; i++ {
+		field := modelType.Field(i)
+		tag, ok := field.Tag.Lookup("ref")
+		if !ok || len(tag) == 0 {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		target := ""
+		if len(parts) > 1 && len(parts[1]) > 0 {
+			target = parts[1]
+		} else if strings.HasSuffix(field.Name, "Ids") {
+			target = strings.TrimSuffix(field.Name, "Ids") + "s"
+		} else {
+			target = strings.TrimSuffix(field.Name, "Id")
+		}
+		tf, ok := modelType.FieldByName(target)
+		if !ok || len(tf.Index) != 1 || tf.Index[0] == i {
+			panic(modelType.Name() + " struct does not have field " + target + " to populate " + field.Name)
+		}
+		if tf.Tag.Get("bson") != "-" {
+			panic(modelType.Name() + "." + target + " must be tagged bson:\"-\" to populate " + field.Name)
+		}
+		ref := Ref{Collection: parts[0], Index: i, Target: tf.Index[0]}
+		for _, option := range parts[2:] {
+			switch option {
+			case "objectId":
+				ref.ObjectId = true
+			case "scoped":
+				ref.Scoped = true
+			}
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+// Populate loads the referenced documents of all models by one $in query per reference, and sets them to the target fields.
+// db is the database of the referenced collections, such as the database of the tenant. Each $in query is scoped by the Scope of its Ref.
+func Populate[T any](ctx context.Context, db *mongo.Database, models []T, refs []Ref) error {
+	l := len(models)
+	if l == 0 {
+		return nil
+	}
+	for _, ref := range refs {
+		ids := make([]interface{}, 0)
+		keys := make(map[string]bool)
+		for i := 0; i < l; i++ {
+			for _, id := range refIds(reflect.ValueOf(&models[i]).Elem().Field(ref.Index), ref.ObjectId) {
+				key := ToKey(id)
+				if !keys[key] {
+					keys[key] = true
+					ids = append(ids, id)
+				}
+			}
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		filter := bson.D{{Key: "_id", Value: bson.M{"$in": ids}}}
+		if ref.Scope != nil {
+			var err error
+			if filter, err = ref.Scope(ctx, filter); err != nil {
+				return err
+			}
+		}
+		cursor, err := db.Collection(ref.Collection).Find(ctx, filter)
+		if err != nil {
+			return err
+		}
+		var docs []bson.Raw
+		if err = cursor.All(ctx, &docs); err != nil {
+			return err
+		}
+		var t T
+		targetType := reflect.TypeOf(t).Field(ref.Target).Type
+		elemType := targetType
+		if elemType.Kind() == reflect.Slice {
+			elemType = elemType.Elem()
+		}
+		if elemType.Kind() == reflect.Ptr {
+			elemType = elemType.Elem()
+		}
+		values := make(map[string]reflect.Value)
+		for _, doc := range docs {
+			var id interface{}
+			if err = doc.Lookup("_id").Unmarshal(&id); err != nil {
+				return err
+			}
+			v := reflect.New(elemType)
+			if err = bson.Unmarshal(doc, v.Interface()); err != nil {
+				return err
+			}
+			if ref.DbToModel != nil {
+				ref.DbToModel(v.Interface())
+			}
+			values[ToKey(id)] = v
+		}
+		for i := 0; i < l; i++ {
+			vo := reflect.ValueOf(&models[i]).Elem()
+			setRef(vo.Field(ref.Target), refIds(vo.Field(ref.Index), ref.ObjectId), values)
+		}
+	}
+	return nil
+}
+func refIds(f reflect.Value, objectId bool) []interface{} {
+	ids := make([]interface{}, 0)
+	if f.Kind() == reflect.Ptr {
+		if f.IsNil() {
+			return ids
+		}
+		f = f.Elem()
+	}
+	if f.Kind() == reflect.Slice && f.Type().Elem().Kind() != reflect.Uint8 {
+		for j := 0; j < f.Len(); j++ {
+			ids = append(ids, refIds(f.Index(j), objectId)...)
+		}
+		return ids
+	}
+	if f.IsZero() {
+		return ids
+	}
+	id := f.Interface()
+	if s, ok := id.(string); ok && objectId {
+		oid, err := primitive.ObjectIDFromHex(s)
+		if err != nil {
+			return ids
+		}
+		id = oid
+	}
+	return append(ids, id)
+}
+func setRef(target reflect.Value, ids []interface{}, values map[string]reflect.Value) {
+	if target.Kind() == reflect.Slice {
+		arr := reflect.MakeSlice(target.Type(), 0, len(ids))
+		for _, id := range ids {
+			if v, ok := values[ToKey(id)]; ok {
+				if target.Type().Elem().Kind() == reflect.Ptr {
+					arr = reflect.Append(arr, v)
+				} else {
+					arr = reflect.Append(arr, v.Elem())
+				}
+			}
+		}
+		target.Set(arr)
+		return
+	}
+	if len(ids) == 0 {
+		return
+	}
+	v, ok := values[ToKey(ids[0])]
+	if !ok {
+		return
+	}
+	if target.Kind() == reflect.Ptr {
+		target.Set(v)
+	} else {
+		target.Set(v.Elem())
+	}
+}
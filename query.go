@@ -2,13 +2,18 @@ package mongo
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"reflect"
 	"strings"
 )
 
+var ErrInvalidCursorToken = errors.New("invalid cursor token")
+
 func BuildSearchResult(ctx context.Context, collection *mongo.Collection, results interface{}, query bson.D, fields bson.M, sort bson.D, limit int64, skip int64) (int64, error) {
 	optionsFind := options.Find()
 	if fields != nil {
@@ -114,3 +119,184 @@ func GetFields(fields []string, modelType reflect.Type) bson.M {
 	}
 	return fs
 }
+
+// BuildSearchResultWithCursor is the keyset pagination of BuildSearchResult.
+// It appends _id to sort as a tiebreaker, loads the rows after the row of nextToken, and returns the token of the next page, which is empty on the last page.
+func BuildSearchResultWithCursor(ctx context.Context, collection *mongo.Collection, results interface{}, query bson.D, fields bson.M, sort bson.D, limit int64, nextToken string) (string, error) {
+	sort = EnsureIdSort(sort)
+	if len(nextToken) > 0 {
+		values, err := DecodeCursorToken(nextToken, sort)
+		if err != nil {
+			return "", err
+		}
+		cursorQuery := BuildCursorQuery(sort, values)
+		if len(query) > 0 {
+			query = bson.D{{Key: "$and", Value: bson.A{query, cursorQuery}}}
+		} else {
+			query = cursorQuery
+		}
+	}
+	optionsFind := options.Find()
+	if fields != nil {
+		projection := bson.M{}
+		for k, v := range fields {
+			projection[k] = v
+		}
+		for _, s := range sort {
+			projection[s.Key] = 1
+		}
+		optionsFind.Projection = projection
+	}
+	if limit > 0 {
+		optionsFind.SetLimit(limit + 1)
+	}
+	optionsFind.SetSort(sort)
+	cursor, er0 := collection.Find(ctx, query, optionsFind)
+	if er0 != nil {
+		return "", er0
+	}
+	defer cursor.Close(ctx)
+	// the token is built from the stored document of the last row, not from the decoded row, so that an ObjectID is not encoded as its hex string
+	rv := reflect.Indirect(reflect.ValueOf(results))
+	rows := reflect.MakeSlice(rv.Type(), 0, int(limit))
+	var last bson.Raw
+	more := false
+	for cursor.Next(ctx) {
+		if limit > 0 && int64(rows.Len()) == limit {
+			more = true
+			break
+		}
+		row := reflect.New(rv.Type().Elem())
+		if er1 := cursor.Decode(row.Interface()); er1 != nil {
+			return "", er1
+		}
+		rows = reflect.Append(rows, row.Elem())
+		last = append(bson.Raw(nil), cursor.Current...)
+	}
+	if er2 := cursor.Err(); er2 != nil {
+		return "", er2
+	}
+	rv.Set(rows)
+	if !more {
+		return "", nil
+	}
+	return BuildCursorToken(last, sort)
+}
+func EnsureIdSort(sort bson.D) bson.D {
+	for _, s := range sort {
+		if s.Key == "_id" {
+			return sort
+		}
+	}
+	sorts := make(bson.D, 0, len(sort)+1)
+	sorts = append(sorts, sort...)
+	return append(sorts, bson.E{Key: "_id", Value: 1})
+}
+
+// BuildCursorQuery builds the range predicate to get the rows after the row having values of sort fields.
+// Null and missing values are sorted first, as MongoDB does.
+func BuildCursorQuery(sort bson.D, values []bson.RawValue) bson.D {
+	or := bson.A{}
+	for i, s := range sort {
+		q := bson.D{}
+		for j := 0; j < i; j++ {
+			q = append(q, bson.E{Key: sort[j].Key, Value: values[j]})
+		}
+		isNull := values[i].Type == bsontype.Null
+		if isDescending(s.Value) {
+			if isNull {
+				continue
+			}
+			q = append(q, bson.E{Key: "$or", Value: bson.A{bson.D{{Key: s.Key, Value: bson.D{{Key: "$lt", Value: values[i]}}}}, bson.D{{Key: s.Key, Value: nil}}}})
+		} else if isNull {
+			q = append(q, bson.E{Key: s.Key, Value: bson.D{{Key: "$ne", Value: nil}}})
+		} else {
+			q = append(q, bson.E{Key: s.Key, Value: bson.D{{Key: "$gt", Value: values[i]}}})
+		}
+		or = append(or, q)
+	}
+	if len(or) == 0 {
+		// the last row has null values in descending order, so there is no row after it
+		return bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: bson.A{}}}}}
+	}
+	return bson.D{{Key: "$or", Value: or}}
+}
+func isDescending(v interface{}) bool {
+	switch d := v.(type) {
+	case int:
+		return d < 0
+	case int32:
+		return d < 0
+	case int64:
+		return d < 0
+	case float64:
+		return d < 0
+	}
+	return false
+}
+
+// BuildCursorToken encodes the values of the sort fields of the row, and the sort, so that the token cannot be used with another sort.
+// The missing values, such as omitempty fields, are null. The row should be the stored document (bson.Raw), because a decoded struct may have other types, such as a string id of an ObjectID.
+func BuildCursorToken(row interface{}, sort bson.D) (string, error) {
+	raw, ok := row.(bson.Raw)
+	if !ok {
+		data, err := bson.Marshal(row)
+		if err != nil {
+			return "", err
+		}
+		raw = data
+	}
+	values := bson.A{}
+	for _, s := range sort {
+		v, er1 := raw.LookupErr(strings.Split(s.Key, ".")...)
+		if er1 != nil {
+			values = append(values, nil)
+			continue
+		}
+		values = append(values, v)
+	}
+	token, err := bson.Marshal(bson.D{{Key: "v", Value: values}, {Key: "s", Value: sortKey(sort)}})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+func DecodeCursorToken(token string, sort bson.D) ([]bson.RawValue, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursorToken
+	}
+	raw := bson.Raw(data)
+	if raw.Validate() != nil {
+		return nil, ErrInvalidCursorToken
+	}
+	if s, ok := raw.Lookup("s").StringValueOK(); !ok || s != sortKey(sort) {
+		return nil, ErrInvalidCursorToken
+	}
+	v, err := raw.LookupErr("v")
+	if err != nil {
+		return nil, ErrInvalidCursorToken
+	}
+	arr, ok := v.ArrayOK()
+	if !ok {
+		return nil, ErrInvalidCursorToken
+	}
+	values, err := arr.Values()
+	if err != nil || len(values) != len(sort) {
+		return nil, ErrInvalidCursorToken
+	}
+	return values, nil
+}
+
+// sortKey is the fingerprint of the sort in the cursor token, such as "name:1,_id:1"
+func sortKey(sort bson.D) string {
+	keys := make([]string, 0, len(sort))
+	for _, s := range sort {
+		direction := "1"
+		if isDescending(s.Value) {
+			direction = "-1"
+		}
+		keys = append(keys, s.Key+":"+direction)
+	}
+	return strings.Join(keys, ",")
+}
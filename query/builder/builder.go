@@ -14,6 +14,18 @@ var Operators = map[string]string{
 	">":  "$gt",
 	"<=": "$lte",
 	"<":  "$lt",
+	"!=": "$ne",
+	// exists is for bool fields, size for int fields and elemMatch for struct fields, which are the filters of the array elements
+	"exists":    "$exists",
+	"size":      "$size",
+	"elemMatch": "$elemMatch",
+}
+
+// ArrayOperators are the operators of the slice fields. The slices are $in by default.
+var ArrayOperators = map[string]string{
+	"in":  "$in",
+	"nin": "$nin",
+	"all": "$all",
 }
 
 func UseQueryByResultType[F any](resultModelType reflect.Type, extract func(F) ([]string, string, []string)) func(filter F) (bson.D, bson.M) {
@@ -143,14 +155,29 @@ func Build(filter interface{}, resultModelType reflect.Type, arrFields []string,
 			}
 		} else if kind == reflect.Slice {
 			if field.Len() > 0 {
+				opr := "$in"
+				if oper, ok := tf.Tag.Lookup("operator"); ok {
+					if o, ok2 := ArrayOperators[oper]; ok2 {
+						opr = o
+					}
+				}
 				arrQuery := bson.M{}
-				arrQuery["$in"] = x
+				arrQuery[opr] = x
 				query = append(query, bson.E{Key: bsonName, Value: arrQuery})
 			}
+		} else if r, ok := toRange(x); ok {
+			if len(r) > 0 && len(bsonName) > 0 {
+				query = append(query, bson.E{Key: bsonName, Value: r})
+			}
 		} else {
 			if len(bsonName) > 0 {
 				oper, ok1 := tf.Tag.Lookup("operator")
-				if ok1 {
+				if ok1 && oper == "elemMatch" && kind == reflect.Struct {
+					sub, _ := Build(x, elemType(resultModelType, tf.Name), nil, "", nil)
+					if len(sub) > 0 {
+						query = append(query, bson.E{Key: bsonName, Value: bson.M{"$elemMatch": sub}})
+					}
+				} else if ok1 {
 					opr, ok2 := Operators[oper]
 					if ok2 {
 						dQuery := bson.M{}
@@ -190,6 +217,22 @@ func getFieldByJson(modelType reflect.Type, jsonName string) (int, string, strin
 	}
 	return -1, jsonName, jsonName
 }
+
+// elemType returns the element type of the slice field, to get the bson names of the elemMatch filter
+func elemType(modelType reflect.Type, fieldName string) reflect.Type {
+	field, found := modelType.FieldByName(fieldName)
+	if !found {
+		return modelType
+	}
+	t := field.Type
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return modelType
+	}
+	return t
+}
 func getBsonName(modelType reflect.Type, fieldName string) string {
 	field, found := modelType.FieldByName(fieldName)
 	if !found {
@@ -0,0 +1,67 @@
+package query
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// TimeRange is the filter of a time window. Min and Max are inclusive ($gte and $lte), Lower and Upper are exclusive ($gt and $lt). The nil bounds are ignored.
+type TimeRange struct {
+	Min   *time.Time `json:"min,omitempty" bson:"min,omitempty"`
+	Max   *time.Time `json:"max,omitempty" bson:"max,omitempty"`
+	Lower *time.Time `json:"lower,omitempty" bson:"lower,omitempty"`
+	Upper *time.Time `json:"upper,omitempty" bson:"upper,omitempty"`
+}
+
+// NumberRange is the filter of a number range. Min and Max are inclusive ($gte and $lte), Lower and Upper are exclusive ($gt and $lt). The nil bounds are ignored.
+type NumberRange struct {
+	Min   *float64 `json:"min,omitempty" bson:"min,omitempty"`
+	Max   *float64 `json:"max,omitempty" bson:"max,omitempty"`
+	Lower *float64 `json:"lower,omitempty" bson:"lower,omitempty"`
+	Upper *float64 `json:"upper,omitempty" bson:"upper,omitempty"`
+}
+
+func (r TimeRange) Query() bson.M {
+	q := bson.M{}
+	if r.Min != nil {
+		q["$gte"] = *r.Min
+	}
+	if r.Lower != nil {
+		q["$gt"] = *r.Lower
+	}
+	if r.Max != nil {
+		q["$lte"] = *r.Max
+	}
+	if r.Upper != nil {
+		q["$lt"] = *r.Upper
+	}
+	return q
+}
+func (r NumberRange) Query() bson.M {
+	q := bson.M{}
+	if r.Min != nil {
+		q["$gte"] = *r.Min
+	}
+	if r.Lower != nil {
+		q["$gt"] = *r.Lower
+	}
+	if r.Max != nil {
+		q["$lte"] = *r.Max
+	}
+	if r.Upper != nil {
+		q["$lt"] = *r.Upper
+	}
+	return q
+}
+
+// toRange returns the query of TimeRange and NumberRange
+func toRange(x interface{}) (bson.M, bool) {
+	switch r := x.(type) {
+	case TimeRange:
+		return r.Query(), true
+	case NumberRange:
+		return r.Query(), true
+	}
+	return nil, false
+}
@@ -2,9 +2,7 @@ package query
 
 import (
 	"context"
-	"fmt"
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"reflect"
 
@@ -17,6 +15,8 @@ type Loader[T any, K any] struct {
 	idIndex    int
 	idJson     string
 	Map        func(*T)
+	Tenant     *mgo.Tenant
+	mgo.Options
 }
 
 func NewMongoLoader[T any, K any](db *mongo.Database, collectionName string, idObjectId bool, options ...func(*T)) *Loader[T, K] {
@@ -34,13 +34,19 @@ func NewMongoLoader[T any, K any](db *mongo.Database, collectionName string, idO
 		panic(modelType.Name() + " Loader can't use functions that need Id value (Ex Load, Exist) because don't have any fields of " + modelType.Name() + " struct define _id bson tag.")
 	}
 	adapter := &Loader[T, K]{Collection: db.Collection(collectionName), idJson: jsonIdName, idIndex: idIndex, ObjectId: idObjectId, Map: mapper}
+	adapter.Options = mgo.NewOptions(&adapter.Collection)
 	return adapter
 }
 func NewLoader[T any, K any](db *mongo.Database, collectionName string, options ...func(*T)) *Loader[T, K] {
 	return NewMongoLoader[T, K](db, collectionName, false, options...)
 }
 func (a *Loader[T, K]) All(ctx context.Context) ([]T, error) {
-	filter := bson.M{}
+	ctx, cancel := mgo.WithTimeout(ctx, a.Timeout())
+	defer cancel()
+	filter, err := scopeMap(ctx, a.Tenant, bson.M{})
+	if err != nil {
+		return nil, err
+	}
 	cursor, err := a.Collection.Find(ctx, filter)
 	if err != nil {
 		return nil, err
@@ -59,21 +65,28 @@ func (a *Loader[T, K]) All(ctx context.Context) ([]T, error) {
 	return objs, nil
 }
 func (a *Loader[T, K]) Load(ctx context.Context, id K) (*T, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, a.Timeout())
+	defer cancel()
 	var res T
 	if a.ObjectId {
-		objId := fmt.Sprintf("%v", id)
-		objectId, err := primitive.ObjectIDFromHex(objId)
+		objectId, err := mgo.ToId(id, true)
+		if err != nil {
+			return nil, err
+		}
+		query, err := scopeMap(ctx, a.Tenant, bson.M{"_id": objectId})
 		if err != nil {
 			return nil, err
 		}
-		query := bson.M{"_id": objectId}
 		ok, er0 := mgo.FindOne(ctx, a.Collection, query, &res)
 		if ok && er0 == nil && a.Map != nil {
 			a.Map(&res)
 		}
 		return &res, er0
 	}
-	query := bson.M{"_id": id}
+	query, er1 := scopeMap(ctx, a.Tenant, bson.M{"_id": id})
+	if er1 != nil {
+		return nil, er1
+	}
 	ok, er2 := mgo.FindOne(ctx, a.Collection, query, &res)
 	if er2 != nil {
 		return nil, er2
@@ -87,14 +100,51 @@ func (a *Loader[T, K]) Load(ctx context.Context, id K) (*T, error) {
 	return &res, er2
 }
 
+// LoadMany loads the models in the order of ids, and returns the ids which are not found
+func (a *Loader[T, K]) LoadMany(ctx context.Context, ids []K) ([]T, []K, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, a.Timeout())
+	defer cancel()
+	query, err := scopeMap(ctx, a.Tenant, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	objs, missing, err := mgo.LoadMany[T, K](ctx, a.Collection, ids, a.idIndex, a.ObjectId, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	if a.Map != nil {
+		l := len(objs)
+		for i := 0; i < l; i++ {
+			a.Map(&objs[i])
+		}
+	}
+	return objs, missing, nil
+}
 func (a *Loader[T, K]) Exist(ctx context.Context, id K) (bool, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, a.Timeout())
+	defer cancel()
+	var oid interface{} = id
 	if a.ObjectId {
-		objId := fmt.Sprintf("%v", id)
-		objectId, err := primitive.ObjectIDFromHex(objId)
+		objectId, err := mgo.ToId(id, true)
+		if err != nil {
+			return false, err
+		}
+		oid = objectId
+	}
+	if a.Tenant != nil {
+		filter, err := a.Tenant.FilterMap(ctx, bson.M{"_id": oid})
 		if err != nil {
 			return false, err
 		}
-		return mgo.Exist(ctx, a.Collection, objectId)
+		return mgo.ExistByFilter(ctx, a.Collection, filter)
+	}
+	return mgo.Exist(ctx, a.Collection, oid)
+}
+
+// TenantKey returns the key of the tenant id in the context, or an empty string if the tenant mode is off, so that cache.NewLoader scopes the cache by the tenant
+func (a *Loader[T, K]) TenantKey() string {
+	if a.Tenant == nil {
+		return ""
 	}
-	return mgo.Exist(ctx, a.Collection, id)
+	return a.Tenant.Key
 }
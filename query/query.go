@@ -6,6 +6,7 @@ import (
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 
 	mgo "github.com/core-go/mongo"
 )
@@ -16,6 +17,7 @@ type Query[T any, K any, F any] struct {
 	GetSort    func(m interface{}) string
 	BuildSort  func(s string, modelType reflect.Type) bson.D
 	ModelType  reflect.Type
+	search     mgo.SearchOptions
 }
 
 func NewNewQueryWithSort[T any, K any, F any](db *mongo.Database, collectionName string, buildQuery func(m F) (bson.D, bson.M), getSort func(interface{}) string, buildSort func(string, reflect.Type) bson.D, idObjectId bool, options ...func(*T)) *Query[T, K, F] {
@@ -32,8 +34,14 @@ func NewQuery[T any, K any, F any](db *mongo.Database, collectionName string, bu
 	return &Query[T, K, F]{Loader: adapter, BuildSort: mgo.BuildSort, GetSort: getSort, BuildQuery: buildQuery, ModelType: modelType}
 }
 func (b *Query[T, K, F]) Search(ctx context.Context, m F, limit int64, skip int64) ([]T, int64, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, b.Timeout())
+	defer cancel()
 	var objs []T
 	query, fields := b.BuildQuery(m)
+	query, err := scope(ctx, b.Tenant, query)
+	if err != nil {
+		return nil, 0, err
+	}
 
 	var sort = bson.D{}
 	s := b.GetSort(m)
@@ -42,8 +50,7 @@ func (b *Query[T, K, F]) Search(ctx context.Context, m F, limit int64, skip int6
 		skip = 0
 	}
 	var total int64
-	var err error
-	total, err = mgo.BuildSearchResult(ctx, b.Collection, &objs, query, fields, sort, limit, skip)
+	total, err = mgo.BuildSearchResult(ctx, b.searchCollection(), &objs, query, fields, sort, limit, skip)
 	if b.Map != nil {
 		l := len(objs)
 		for i := 0; i < l; i++ {
@@ -52,3 +59,36 @@ func (b *Query[T, K, F]) Search(ctx context.Context, m F, limit int64, skip int6
 	}
 	return objs, total, err
 }
+
+// SearchWithCursor is the keyset pagination of Search, which returns the token of the next page
+func (b *Query[T, K, F]) SearchWithCursor(ctx context.Context, m F, limit int64, nextToken string) ([]T, string, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, b.Timeout())
+	defer cancel()
+	var objs []T
+	query, fields := b.BuildQuery(m)
+	query, err := scope(ctx, b.Tenant, query)
+	if err != nil {
+		return nil, "", err
+	}
+	s := b.GetSort(m)
+	sort := b.BuildSort(s, b.ModelType)
+	next, err := mgo.BuildSearchResultWithCursor(ctx, b.searchCollection(), &objs, query, fields, sort, limit, nextToken)
+	if err != nil {
+		return nil, "", err
+	}
+	if b.Map != nil {
+		l := len(objs)
+		for i := 0; i < l; i++ {
+			b.Map(&objs[i])
+		}
+	}
+	return objs, next, nil
+}
+
+// SetSearchOptions sets the options of the collection of Search and SearchWithCursor
+func (b *Query[T, K, F]) SetSearchOptions(opts ...*options.CollectionOptions) error {
+	return b.search.Set(b.Collection, opts...)
+}
+func (b *Query[T, K, F]) searchCollection() *mongo.Collection {
+	return b.search.Collection(b.Collection)
+}
@@ -16,6 +16,8 @@ type SearchBuilder[T any, F any] struct {
 	GetSort    func(m interface{}) string
 	BuildSort  func(s string, modelType reflect.Type) bson.D
 	Map        func(*T)
+	Tenant     *mgo.Tenant
+	mgo.Options
 }
 
 func NewSearchQueryWithSort[T any, F any](db *mongo.Database, collectionName string, buildQuery func(F) (bson.D, bson.M), getSort func(interface{}) string, buildSort func(string, reflect.Type) bson.D, options ...func(*T)) *SearchBuilder[T, F] {
@@ -31,14 +33,21 @@ func NewSearchBuilderWithSort[T any, F any](db *mongo.Database, collectionName s
 	}
 	collection := db.Collection(collectionName)
 	builder := &SearchBuilder[T, F]{Collection: collection, BuildQuery: buildQuery, GetSort: getSort, BuildSort: buildSort, Map: mp}
+	builder.Options = mgo.NewOptions(&builder.Collection)
 	return builder
 }
 func NewSearchBuilder[T any, F any](db *mongo.Database, collectionName string, buildQuery func(F) (bson.D, bson.M), getSort func(interface{}) string, options ...func(*T)) *SearchBuilder[T, F] {
 	return NewSearchBuilderWithSort[T, F](db, collectionName, buildQuery, getSort, mgo.BuildSort, options...)
 }
 func (b *SearchBuilder[T, F]) Search(ctx context.Context, m F, limit int64, skip int64) ([]T, int64, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, b.Timeout())
+	defer cancel()
 	var objs []T
 	query, fields := b.BuildQuery(m)
+	query, err := scope(ctx, b.Tenant, query)
+	if err != nil {
+		return nil, 0, err
+	}
 
 	var sort = bson.D{}
 	s := b.GetSort(m)
@@ -0,0 +1,33 @@
+package query
+
+import (
+	"context"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	mgo "github.com/core-go/mongo"
+)
+
+// SetTenant scopes all queries by the tenant id, which is read from ctx.Value(tenantKey). tenantField is the struct field name, or the bson name of the tenant id.
+// All calls without a tenant in ctx fail with mgo.ErrNoTenant.
+func (a *Loader[T, K]) SetTenant(tenantKey string, tenantField string) {
+	var t T
+	a.Tenant = mgo.NewTenant(reflect.TypeOf(t), tenantKey, tenantField)
+}
+func (b *SearchBuilder[T, F]) SetTenant(tenantKey string, tenantField string) {
+	var t T
+	b.Tenant = mgo.NewTenant(reflect.TypeOf(t), tenantKey, tenantField)
+}
+func scope(ctx context.Context, tenant *mgo.Tenant, filter bson.D) (bson.D, error) {
+	if tenant == nil {
+		return filter, nil
+	}
+	return tenant.Filter(ctx, filter)
+}
+func scopeMap(ctx context.Context, tenant *mgo.Tenant, filter bson.M) (bson.M, error) {
+	if tenant == nil {
+		return filter, nil
+	}
+	return tenant.FilterMap(ctx, filter)
+}
@@ -0,0 +1,78 @@
+package mongo
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCursorToken(t *testing.T) {
+	id := primitive.NewObjectID()
+	row, _ := bson.Marshal(bson.D{{Key: "_id", Value: id}, {Key: "name", Value: "a"}, {Key: "a", Value: bson.D{{Key: "b", Value: int32(2)}}}})
+	sort := bson.D{{Key: "name", Value: -1}, {Key: "a.b", Value: 1}, {Key: "age", Value: 1}, {Key: "_id", Value: 1}}
+	token, err := BuildCursorToken(bson.Raw(row), sort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := DecodeCursorToken(token, sort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 4 || values[0].StringValue() != "a" || values[1].Int32() != 2 || values[2].Type != bson.TypeNull || values[3].ObjectID() != id {
+		t.Errorf("DecodeCursorToken() = %v", values)
+	}
+	tests := []struct {
+		name  string
+		token string
+		sort  bson.D
+	}{
+		{"other direction", token, bson.D{{Key: "name", Value: 1}, {Key: "a.b", Value: 1}, {Key: "age", Value: 1}, {Key: "_id", Value: 1}}},
+		{"other fields", token, bson.D{{Key: "name", Value: -1}, {Key: "_id", Value: 1}}},
+		{"bad base64", "***", sort},
+		{"bad bson", "AAAA", sort},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeCursorToken(tt.token, tt.sort); err != ErrInvalidCursorToken {
+				t.Errorf("DecodeCursorToken() error = %v, want ErrInvalidCursorToken", err)
+			}
+		})
+	}
+}
+
+func TestBuildCursorQuery(t *testing.T) {
+	value := func(v interface{}) bson.RawValue {
+		t, b, _ := bson.MarshalValue(v)
+		return bson.RawValue{Type: t, Value: b}
+	}
+	a, one, null := value("a"), value(int32(1)), bson.RawValue{Type: bson.TypeNull}
+	tests := []struct {
+		name   string
+		sort   bson.D
+		values []bson.RawValue
+		want   bson.D
+	}{
+		{"asc", bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}}, []bson.RawValue{a, one}, bson.D{{Key: "$or", Value: bson.A{
+			bson.D{{Key: "name", Value: bson.D{{Key: "$gt", Value: a}}}},
+			bson.D{{Key: "name", Value: a}, {Key: "_id", Value: bson.D{{Key: "$gt", Value: one}}}},
+		}}}},
+		{"desc", bson.D{{Key: "name", Value: -1}, {Key: "_id", Value: 1}}, []bson.RawValue{a, one}, bson.D{{Key: "$or", Value: bson.A{
+			bson.D{{Key: "$or", Value: bson.A{bson.D{{Key: "name", Value: bson.D{{Key: "$lt", Value: a}}}}, bson.D{{Key: "name", Value: nil}}}}},
+			bson.D{{Key: "name", Value: a}, {Key: "_id", Value: bson.D{{Key: "$gt", Value: one}}}},
+		}}}},
+		{"null asc", bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}}, []bson.RawValue{null, one}, bson.D{{Key: "$or", Value: bson.A{
+			bson.D{{Key: "name", Value: bson.D{{Key: "$ne", Value: nil}}}},
+			bson.D{{Key: "name", Value: null}, {Key: "_id", Value: bson.D{{Key: "$gt", Value: one}}}},
+		}}}},
+		{"null desc", bson.D{{Key: "name", Value: -1}}, []bson.RawValue{null}, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: bson.A{}}}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := BuildCursorQuery(tt.sort, tt.values); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("BuildCursorQuery() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
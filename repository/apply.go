@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	mgo "github.com/core-go/mongo"
+)
+
+const OpApply = "apply"
+
+// Apply updates a document atomically by the update operators, such as $inc, $push and $pull.
+// If the repository has a version field, version must be passed, the document is updated only if it has this version, and the version is increased.
+// BeforePatch is not called, because the update operators are not a patch of the fields.
+func (a *Repository[T, K]) Apply(ctx context.Context, id K, update *mgo.Update, version ...interface{}) (int64, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, a.Timeout())
+	defer cancel()
+	if update.IsEmpty() {
+		return 0, errors.New("update must not be empty")
+	}
+	return a.inHistory(ctx, nil, func(ctx context.Context) (int64, error) { return a.apply(ctx, id, update, version...) })
+}
+func (a *Repository[T, K]) apply(ctx context.Context, id K, update *mgo.Update, version ...interface{}) (int64, error) {
+	oid, err := a.toId(id)
+	if err != nil {
+		return 0, err
+	}
+	filter, err := a.idFilter(ctx, oid)
+	if err != nil {
+		return 0, err
+	}
+	if a.deleteIndex >= 0 {
+		filter = append(filter, bson.E{Key: a.deleteBson, Value: a.notDeleted()})
+	}
+	u := update.Clone()
+	checkVersion := false
+	if a.versionIndex >= 0 {
+		var t T
+		versionType := reflect.TypeOf(t).Field(a.versionIndex).Type
+		if len(version) == 0 || version[0] == nil {
+			return -1, fmt.Errorf("%s must be passed to apply", a.versionJson)
+		}
+		current, ok := mgo.ToVersion(versionType, version[0])
+		if !ok {
+			return -1, errors.New("do not support this version type")
+		}
+		filter = append(filter, bson.E{Key: a.versionBson, Value: current})
+		checkVersion = true
+		switch versionType.Kind() {
+		case reflect.Int, reflect.Int32, reflect.Int64:
+			u.Inc(a.versionJson, 1)
+		default:
+			next, ok := mgo.NewVersion(versionType)
+			if !ok {
+				return -1, errors.New("do not support this version type")
+			}
+			u.Set(a.versionJson, next)
+		}
+	}
+	if a.audit != nil {
+		m := make(map[string]interface{})
+		a.audit.patch(ctx, m, mgo.Now())
+		for k, v := range m {
+			u.Set(k, v)
+		}
+	}
+	prev, err := a.snapshot(ctx, oid)
+	if err != nil {
+		return 0, err
+	}
+	res, err := mgo.ApplyOne(ctx, a.Collection, filter, u.Build(a.Map))
+	if err == nil && res <= 0 && checkVersion {
+		return a.conflict(ctx, oid)
+	}
+	if err == nil && res > 0 && prev != nil {
+		next, er1 := a.snapshot(ctx, oid)
+		if er1 != nil {
+			return res, er1
+		}
+		res, err = a.record(ctx, OpApply, oid, prev, next, res, err)
+	}
+	return a.notFound(res, err)
+}
@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type field struct {
+	index int
+	json  string
+	bson  string
+}
+type audit struct {
+	userKey   string
+	createdBy field
+	createdAt field
+	updatedBy field
+	updatedAt field
+}
+
+func NewMongoRepositoryWithAudit[T any, K any](db *mongo.Database, collectionName string, idObjectId bool, versionField string, createdBy string, createdAt string, updatedBy string, updatedAt string, options ...Mapper[T]) *Repository[T, K] {
+	repo := NewMongoRepositoryWithVersion[T, K](db, collectionName, idObjectId, versionField, options...)
+	repo.SetAudit(createdBy, createdAt, updatedBy, updatedAt)
+	return repo
+}
+func NewRepositoryWithAudit[T any, K any](db *mongo.Database, collectionName string, createdBy string, createdAt string, updatedBy string, updatedAt string, options ...Mapper[T]) *Repository[T, K] {
+	return NewMongoRepositoryWithAudit[T, K](db, collectionName, false, "", createdBy, createdAt, updatedBy, updatedAt, options...)
+}
+
+// SetAudit sets the struct field names of the audit fields. Empty names are skipped.
+// "By" fields must be string or *string, and are filled from ctx.Value(userKey). The default userKey is "userId".
+// "At" fields must be time.Time or *time.Time.
+func (a *Repository[T, K]) SetAudit(createdBy string, createdAt string, updatedBy string, updatedAt string, options ...string) {
+	var t T
+	modelType := reflect.TypeOf(t)
+	userKey := "userId"
+	if len(options) > 0 && len(options[0]) > 0 {
+		userKey = options[0]
+	}
+	a.audit = &audit{
+		userKey:   userKey,
+		createdBy: findAuditField(modelType, createdBy),
+		createdAt: findAuditField(modelType, createdAt),
+		updatedBy: findAuditField(modelType, updatedBy),
+		updatedAt: findAuditField(modelType, updatedAt),
+	}
+}
+func findAuditField(modelType reflect.Type, name string) field {
+	if len(name) == 0 {
+		return field{index: -1}
+	}
+	index, jsonName, bsonName := FindFieldByName(modelType, name)
+	return field{index: index, json: jsonName, bson: bsonName}
+}
+func (s *audit) user(ctx context.Context) string {
+	if u, ok := ctx.Value(s.userKey).(string); ok {
+		return u
+	}
+	return ""
+}
+func (s *audit) hasCreated() bool {
+	return s.createdBy.index >= 0 || s.createdAt.index >= 0
+}
+func (s *audit) create(ctx context.Context, vo reflect.Value, now time.Time) {
+	user := s.user(ctx)
+	setUser(vo, s.createdBy.index, user)
+	setTime(vo, s.createdAt.index, now)
+	s.update(ctx, vo, now)
+}
+func (s *audit) update(ctx context.Context, vo reflect.Value, now time.Time) {
+	setUser(vo, s.updatedBy.index, s.user(ctx))
+	setTime(vo, s.updatedAt.index, now)
+}
+
+// patch removes the created fields, which clients must not change, and sets the updated fields
+func (s *audit) patch(ctx context.Context, model map[string]interface{}, now time.Time) {
+	if s.createdBy.index >= 0 {
+		delete(model, s.createdBy.json)
+	}
+	if s.createdAt.index >= 0 {
+		delete(model, s.createdAt.json)
+	}
+	if user := s.user(ctx); s.updatedBy.index >= 0 && len(user) > 0 {
+		model[s.updatedBy.json] = user
+	}
+	if s.updatedAt.index >= 0 {
+		model[s.updatedAt.json] = now
+	}
+}
+
+// copyCreated copies the created fields from src to dst
+func (s *audit) copyCreated(dst reflect.Value, src reflect.Value) {
+	for _, i := range []int{s.createdBy.index, s.createdAt.index} {
+		if i >= 0 {
+			dst.Field(i).Set(src.Field(i))
+		}
+	}
+}
+
+// split returns the document for $set without the created fields, and the created fields for $setOnInsert
+func (s *audit) split(model interface{}) (bson.D, bson.D, error) {
+	data, err := bson.Marshal(model)
+	if err != nil {
+		return nil, nil, err
+	}
+	var doc bson.D
+	if err = bson.Unmarshal(data, &doc); err != nil {
+		return nil, nil, err
+	}
+	set := bson.D{}
+	onInsert := bson.D{}
+	for _, e := range doc {
+		if (s.createdBy.index >= 0 && e.Key == s.createdBy.bson) || (s.createdAt.index >= 0 && e.Key == s.createdAt.bson) {
+			onInsert = append(onInsert, e)
+		} else {
+			set = append(set, e)
+		}
+	}
+	return set, onInsert, nil
+}
+func setUser(vo reflect.Value, index int, user string) {
+	if index < 0 || len(user) == 0 {
+		return
+	}
+	f := vo.Field(index)
+	switch f.Type().String() {
+	case "string":
+		f.Set(reflect.ValueOf(user))
+	case "*string":
+		f.Set(reflect.ValueOf(&user))
+	}
+}
+func setTime(vo reflect.Value, index int, now time.Time) {
+	if index < 0 {
+		return
+	}
+	f := vo.Field(index)
+	switch f.Type().String() {
+	case "time.Time":
+		f.Set(reflect.ValueOf(now))
+	case "*time.Time":
+		f.Set(reflect.ValueOf(&now))
+	}
+}
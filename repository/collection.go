@@ -0,0 +1,14 @@
+package repository
+
+import (
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SetSearchOptions sets the options of the collection of Search, SearchWithCursor, Count, ExistBy and FindBy. See mgo.SearchOptions.
+func (b *SearchRepository[T, K, F]) SetSearchOptions(opts ...*options.CollectionOptions) error {
+	return b.search.Set(b.Collection, opts...)
+}
+func (b *SearchRepository[T, K, F]) searchCollection() *mongo.Collection {
+	return b.search.Collection(b.Collection)
+}
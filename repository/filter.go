@@ -0,0 +1,187 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	mgo "github.com/core-go/mongo"
+)
+
+var ErrEmptyFilter = errors.New("filter must not be empty")
+
+// buildFilter builds the query of the filter by BuildQuery, such as query.Build, with the conditions of soft delete and tenant
+func (b *SearchRepository[T, K, F]) buildFilter(ctx context.Context, m F) (bson.D, bson.M, error) {
+	query, fields := b.BuildQuery(m)
+	query, err := b.ScopeFilter(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	return query, fields, nil
+}
+
+// ScopeFilter adds the conditions of soft delete and tenant to the filter, to read the same documents as the repository, such as by an aggregation pipeline
+func (a *Repository[T, K]) ScopeFilter(ctx context.Context, filter bson.D) (bson.D, error) {
+	filter = append(bson.D{}, filter...)
+	if a.deleteIndex >= 0 {
+		filter = append(filter, bson.E{Key: a.deleteBson, Value: a.notDeleted()})
+	}
+	return a.scope(ctx, filter)
+}
+func (b *SearchRepository[T, K, F]) Count(ctx context.Context, m F) (int64, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, b.Timeout())
+	defer cancel()
+	query, _, err := b.buildFilter(ctx, m)
+	if err != nil {
+		return 0, err
+	}
+	return b.searchCollection().CountDocuments(ctx, query)
+}
+func (b *SearchRepository[T, K, F]) ExistBy(ctx context.Context, m F) (bool, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, b.Timeout())
+	defer cancel()
+	query, _, err := b.buildFilter(ctx, m)
+	if err != nil {
+		return false, err
+	}
+	return mgo.ExistByFilter(ctx, b.searchCollection(), query)
+}
+
+// FindBy returns all models of the filter, in the sort of the filter
+func (b *SearchRepository[T, K, F]) FindBy(ctx context.Context, m F) ([]T, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, b.Timeout())
+	defer cancel()
+	query, fields, err := b.buildFilter(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+	opts := options.Find()
+	if len(fields) > 0 {
+		opts.SetProjection(fields)
+	}
+	if sort := b.BuildSort(b.GetSort(m), b.ModelType); len(sort) > 0 {
+		opts.SetSort(sort)
+	}
+	cursor, err := b.searchCollection().Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	objs := make([]T, 0)
+	if err = cursor.All(ctx, &objs); err != nil {
+		return nil, err
+	}
+	if b.Mapper != nil {
+		l := len(objs)
+		for i := 0; i < l; i++ {
+			b.Mapper.DbToModel(&objs[i])
+		}
+	}
+	if err = b.afterLoad(ctx, objs); err != nil {
+		return nil, err
+	}
+	return objs, nil
+}
+
+// writeFilterBy builds the query of the filter for UpdateManyBy and DeleteManyBy. An empty query is rejected with ErrEmptyFilter, so that a filter without conditions does not change all documents.
+func (b *SearchRepository[T, K, F]) writeFilterBy(ctx context.Context, m F) (bson.D, error) {
+	query, _ := b.BuildQuery(m)
+	if len(query) == 0 {
+		return nil, ErrEmptyFilter
+	}
+	return b.ScopeFilter(ctx, query)
+}
+
+// UpdateManyBy sets the fields of patch, which has json names, to all documents of the filter, and increases their versions.
+// An empty filter is rejected with ErrEmptyFilter. BeforePatch is called with a copy of patch. The changes are not written to the history collection.
+func (b *SearchRepository[T, K, F]) UpdateManyBy(ctx context.Context, m F, patch map[string]interface{}) (int64, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, b.Timeout())
+	defer cancel()
+	query, err := b.writeFilterBy(ctx, m)
+	if err != nil {
+		return 0, err
+	}
+	p := make(map[string]interface{}, len(patch))
+	for k, v := range patch {
+		p[k] = v
+	}
+	patch = p
+	if err = mgo.RunBeforePatch(ctx, b.Hooks, patch); err != nil {
+		return 0, err
+	}
+	if b.tenant != nil {
+		if err = b.tenant.StampMap(ctx, patch); err != nil {
+			return 0, err
+		}
+	}
+	if b.Mapper != nil {
+		m, err := mgo.MapToDb(b.Mapper, patch)
+		if err != nil {
+			return 0, err
+		}
+		patch = m
+	}
+	delete(patch, b.idJson)
+	if b.audit != nil {
+		b.audit.patch(ctx, patch, mgo.Now())
+	}
+	set := mgo.MapToBson(patch, b.Map)
+	update := b.nextVersion(set)
+	if len(set) > 0 {
+		update = append(update, bson.E{Key: "$set", Value: set})
+	}
+	if len(update) == 0 {
+		return 0, nil
+	}
+	res, err := b.Collection.UpdateMany(ctx, query, update)
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
+}
+
+// nextVersion removes the version from set, and returns $inc for int versions, or sets a new version to set for the other types.
+// Each document has the same new version, which is unique to this update.
+func (a *Repository[T, K]) nextVersion(set bson.M) bson.D {
+	update := bson.D{}
+	if a.versionIndex < 0 {
+		return update
+	}
+	delete(set, a.versionBson)
+	var t T
+	versionType := reflect.TypeOf(t).Field(a.versionIndex).Type
+	switch versionType.Kind() {
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		update = append(update, bson.E{Key: "$inc", Value: bson.M{a.versionBson: 1}})
+	default:
+		if v, ok := mgo.NewVersion(versionType); ok {
+			set[a.versionBson] = v
+		}
+	}
+	return update
+}
+
+// DeleteManyBy deletes all documents of the filter, or soft-deletes them in soft delete mode. An empty filter is rejected with ErrEmptyFilter.
+// BeforeDelete is not called, because it receives one id, and the ids are not loaded.
+func (b *SearchRepository[T, K, F]) DeleteManyBy(ctx context.Context, m F) (int64, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, b.Timeout())
+	defer cancel()
+	query, err := b.writeFilterBy(ctx, m)
+	if err != nil {
+		return 0, err
+	}
+	if b.deleteIndex >= 0 {
+		res, err := b.Collection.UpdateMany(ctx, query, b.softDeleteUpdate(ctx))
+		if err != nil {
+			return 0, err
+		}
+		return res.ModifiedCount, nil
+	}
+	res, err := b.Collection.DeleteMany(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	mgo "github.com/core-go/mongo"
+)
+
+// output receives the document after the write of UpdateAndGet, PatchAndGet and SaveAndGet
+type output[T any] struct {
+	doc    *T
+	insert bool
+}
+
+func (a *Repository[T, K]) updateOne(ctx context.Context, filter bson.D, doc interface{}, out *output[T]) (int64, error) {
+	if out == nil {
+		return mgo.UpdateOneByFilter(ctx, a.Collection, filter, doc)
+	}
+	var res T
+	n, err := mgo.UpdateOneAndGet(ctx, a.Collection, filter, doc, &res)
+	if err == nil && n > 0 {
+		out.doc = &res
+	}
+	return n, err
+}
+
+// upsertOne returns true if the document is inserted, which is known only with onInsert or out
+func (a *Repository[T, K]) upsertOne(ctx context.Context, filter bson.D, set interface{}, onInsert interface{}, out *output[T]) (int64, bool, error) {
+	if out == nil {
+		if onInsert != nil {
+			update := bson.M{"$set": set, "$setOnInsert": onInsert}
+			res, err := a.Collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+			if err != nil {
+				return 0, false, err
+			}
+			return 1, res.UpsertedCount > 0, nil
+		}
+		res, err := mgo.UpsertOneByFilter(ctx, a.Collection, filter, set)
+		return res, false, err
+	}
+	update := bson.M{"$set": set}
+	if onInsert != nil {
+		update["$setOnInsert"] = onInsert
+	}
+	var res T
+	inserted, err := mgo.UpsertOneAndGet(ctx, a.Collection, filter, update, &res)
+	if err != nil {
+		return 0, false, err
+	}
+	out.doc = &res
+	out.insert = inserted
+	return 1, inserted, nil
+}
+func (a *Repository[T, K]) get(out *output[T]) *T {
+	if out.doc != nil && a.Mapper != nil {
+		a.Mapper.DbToModel(out.doc)
+	}
+	return out.doc
+}
+
+// UpdateAndGet updates the model, and returns the document after the update, in one round trip.
+// If the document is not found or the version does not match, the result is nil. Set ReturnError to get ErrNotFound or ErrVersionConflict.
+func (a *Repository[T, K]) UpdateAndGet(ctx context.Context, model *T) (*T, bool, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, a.Timeout())
+	defer cancel()
+	if err := mgo.RunBeforeUpdate(ctx, a.Hooks, model); err != nil {
+		return nil, false, err
+	}
+	out := &output[T]{}
+	res, err := a.inHistory(ctx, keep(model), func(ctx context.Context) (int64, error) { return a.update(ctx, model, out) })
+	if err != nil || res <= 0 {
+		return nil, false, err
+	}
+	if err = mgo.RunAfterUpdate(ctx, a.Hooks, model); err != nil {
+		return nil, false, err
+	}
+	return a.get(out), false, nil
+}
+
+// PatchAndGet patches the fields of the map, and returns the document after the update, with all fields. The after update hooks run on the returned document.
+func (a *Repository[T, K]) PatchAndGet(ctx context.Context, model map[string]interface{}) (*T, bool, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, a.Timeout())
+	defer cancel()
+	if err := mgo.RunBeforePatch(ctx, a.Hooks, model); err != nil {
+		return nil, false, err
+	}
+	out := &output[T]{}
+	res, err := a.inHistory(ctx, keepMap(model), func(ctx context.Context) (int64, error) { return a.patch(ctx, model, out) })
+	if err != nil || res <= 0 {
+		return nil, false, err
+	}
+	doc := a.get(out)
+	if err = mgo.RunAfterUpdate(ctx, a.Hooks, doc); err != nil {
+		return nil, false, err
+	}
+	return doc, false, nil
+}
+
+// SaveAndGet inserts or updates the model, and returns the document after the write, and true if the document is inserted
+func (a *Repository[T, K]) SaveAndGet(ctx context.Context, model *T) (*T, bool, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, a.Timeout())
+	defer cancel()
+	isNew := a.isNew(model)
+	var err error
+	if isNew {
+		err = mgo.RunBeforeCreate(ctx, a.Hooks, model)
+	} else {
+		err = mgo.RunBeforeUpdate(ctx, a.Hooks, model)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	out := &output[T]{}
+	res, err := a.inHistory(ctx, keep(model), func(ctx context.Context) (int64, error) { return a.save(ctx, model, out) })
+	if err != nil || res <= 0 {
+		return nil, false, err
+	}
+	if isNew || out.insert {
+		err = mgo.RunAfterCreate(ctx, a.Hooks, model)
+	} else {
+		err = mgo.RunAfterUpdate(ctx, a.Hooks, model)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return a.get(out), out.insert, nil
+}
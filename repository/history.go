@@ -0,0 +1,291 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	mgo "github.com/core-go/mongo"
+	"github.com/core-go/mongo/client"
+)
+
+var ErrHistoryDisabled = errors.New("history is not enabled")
+
+const (
+	OpCreate = "create"
+	OpUpdate = "update"
+	OpPatch  = "patch"
+	OpSave   = "save"
+	OpDelete = "delete"
+)
+
+// Revision is the document of the history collection. Snapshot is the document before the operation, and is empty for create.
+type Revision struct {
+	Id       primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	DocId    interface{}        `json:"docId,omitempty" bson:"docId,omitempty"`
+	Tenant   interface{}        `json:"tenant,omitempty" bson:"tenant,omitempty"`
+	Op       string             `json:"op,omitempty" bson:"op,omitempty"`
+	Time     time.Time          `json:"time,omitempty" bson:"time,omitempty"`
+	User     string             `json:"user,omitempty" bson:"user,omitempty"`
+	Snapshot bson.Raw           `json:"snapshot,omitempty" bson:"snapshot,omitempty"`
+	Changes  []Change           `json:"changes,omitempty" bson:"changes,omitempty"`
+}
+type Change struct {
+	Field string      `json:"field,omitempty" bson:"field,omitempty"`
+	Old   interface{} `json:"old,omitempty" bson:"old,omitempty"`
+	New   interface{} `json:"new,omitempty" bson:"new,omitempty"`
+}
+type history struct {
+	collection   *mongo.Collection
+	userKey      string
+	mu           sync.Mutex
+	transactions *bool
+}
+
+func NewRepositoryWithHistory[T any, K any](db *mongo.Database, collectionName string, historyCollectionName string, options ...Mapper[T]) *Repository[T, K] {
+	repo := NewMongoRepositoryWithVersion[T, K](db, collectionName, false, "", options...)
+	repo.SetHistory(historyCollectionName)
+	return repo
+}
+
+// SetHistory turns on history mode. Before Update, Patch, Apply, Save of an existing document and Delete, the previous document is written to historyCollectionName of the same database,
+// with the operation, the time, the user from ctx.Value(userKey) and the changed fields. The default userKey is "userId". Create and Save of a new document write a create revision.
+// The write and its revision run in a transaction, which is retried on transient errors, except on a standalone server, which does not support transactions.
+// An index on {docId: 1, time: 1} of the history collection is recommended.
+func (a *Repository[T, K]) SetHistory(historyCollectionName string, options ...string) {
+	userKey := "userId"
+	if len(options) > 0 && len(options[0]) > 0 {
+		userKey = options[0]
+	}
+	a.history = &history{collection: a.Collection.Database().Collection(historyCollectionName), userKey: userKey}
+}
+
+// snapshot loads the current document, which is nil if the document does not exist
+func (s *history) snapshot(ctx context.Context, collection *mongo.Collection, filter interface{}) (bson.Raw, error) {
+	raw, err := collection.FindOne(ctx, filter).DecodeBytes()
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return raw, nil
+}
+
+// record writes the revision. doc is the document or the fields which were written, and is nil for delete. If there is no previous document, the document is created.
+func (s *history) record(ctx context.Context, op string, id interface{}, tenant interface{}, prev bson.Raw, doc interface{}) error {
+	rev := Revision{DocId: id, Tenant: tenant, Op: OpCreate, Time: mgo.Now()}
+	if prev != nil {
+		changes, err := diff(prev, doc)
+		if err != nil {
+			return err
+		}
+		rev.Op, rev.Snapshot, rev.Changes = op, prev, changes
+	} else if op == OpDelete {
+		return nil
+	}
+	if u, ok := ctx.Value(s.userKey).(string); ok {
+		rev.User = u
+	}
+	_, err := s.collection.InsertOne(ctx, rev)
+	return err
+}
+
+// supportsTransactions checks once if the server is a replica set or a sharded cluster
+func (s *history) supportsTransactions(ctx context.Context) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.transactions == nil {
+		var hello bson.M
+		if err := s.collection.Database().RunCommand(ctx, bson.D{{Key: "isMaster", Value: 1}}).Decode(&hello); err != nil {
+			return false
+		}
+		_, isReplicaSet := hello["setName"]
+		ok := isReplicaSet || hello["msg"] == "isdbgrid"
+		s.transactions = &ok
+	}
+	return *s.transactions
+}
+
+// inHistory runs the write with its snapshot and revision in a transaction, so that the snapshot is the previous document, and the write is rolled back if the revision cannot be written.
+// The transaction is retried on TransientTransactionError. reset, which can be nil, restores the model before each retry, because the write changes the version of the model.
+func (a *Repository[T, K]) inHistory(ctx context.Context, reset func(), write func(ctx context.Context) (int64, error)) (int64, error) {
+	if a.history == nil || mongo.SessionFromContext(ctx) != nil || !a.history.supportsTransactions(ctx) {
+		return write(ctx)
+	}
+	var res int64
+	attempt := 0
+	err := client.WithTransaction(ctx, a.Collection.Database().Client(), func(ctx context.Context) error {
+		if attempt > 0 && reset != nil {
+			reset()
+		}
+		attempt++
+		var err error
+		res, err = write(ctx)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return res, nil
+}
+
+// keep returns the function which restores the model to its current value
+func keep[T any](model *T) func() {
+	saved := *model
+	return func() { *model = saved }
+}
+func keepMap(model map[string]interface{}) func() {
+	saved := make(map[string]interface{}, len(model))
+	for k, v := range model {
+		saved[k] = v
+	}
+	return func() {
+		for k := range model {
+			if _, ok := saved[k]; !ok {
+				delete(model, k)
+			}
+		}
+		for k, v := range saved {
+			model[k] = v
+		}
+	}
+}
+func (a *Repository[T, K]) recordCreate(ctx context.Context, vo reflect.Value, res int64, err error) (int64, error) {
+	if a.history == nil || a.idIndex < 0 && a.keys == nil {
+		return res, err
+	}
+	return a.record(ctx, OpCreate, a.modelId(vo), nil, nil, res, err)
+}
+
+// diff compares the written fields with the previous document. Keys can be in dot notation.
+func diff(prev bson.Raw, doc interface{}) ([]Change, error) {
+	changes := make([]Change, 0)
+	if doc == nil {
+		return changes, nil
+	}
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	elements, err := bson.Raw(data).Elements()
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range elements {
+		key := e.Key()
+		if key == "_id" {
+			continue
+		}
+		v := e.Value()
+		old, er1 := prev.LookupErr(strings.Split(key, ".")...)
+		if er1 == nil && old.Type == v.Type && bytes.Equal(old.Value, v.Value) {
+			continue
+		}
+		c := Change{Field: key, New: v}
+		if er1 == nil {
+			c.Old = old
+		}
+		changes = append(changes, c)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes, nil
+}
+
+// History returns the revisions of the document, from the oldest to the newest
+func (a *Repository[T, K]) History(ctx context.Context, id K) ([]Revision, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, a.Timeout())
+	defer cancel()
+	if a.history == nil {
+		return nil, ErrHistoryDisabled
+	}
+	oid, err := a.toId(id)
+	if err != nil {
+		return nil, err
+	}
+	filter := bson.M{"docId": oid}
+	if a.tenant != nil {
+		tenant, err := a.tenant.Get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		filter["tenant"] = tenant
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "time", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := a.history.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	revisions := make([]Revision, 0)
+	if err = cursor.All(ctx, &revisions); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// LoadAsOf loads the state of the document at the time, which is the snapshot of the first revision after the time, or the current document if there is no revision after the time.
+// If the first revision after the time is the create revision, the document did not exist at the time.
+// The current document is loaded even if it is soft-deleted.
+func (a *Repository[T, K]) LoadAsOf(ctx context.Context, id K, t time.Time) (*T, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, a.Timeout())
+	defer cancel()
+	if a.history == nil {
+		return nil, ErrHistoryDisabled
+	}
+	oid, err := a.toId(id)
+	if err != nil {
+		return nil, err
+	}
+	filter := bson.M{"docId": oid, "time": bson.M{"$gt": t}}
+	current := a.keyMap(oid)
+	if a.tenant != nil {
+		tenant, err := a.tenant.Get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		filter["tenant"] = tenant
+		current[a.tenant.Bson] = tenant
+	}
+	var res T
+	var rev Revision
+	opts := options.FindOne().SetSort(bson.D{{Key: "time", Value: 1}, {Key: "_id", Value: 1}})
+	err = a.history.collection.FindOne(ctx, filter, opts).Decode(&rev)
+	if err == nil {
+		if rev.Op == OpCreate {
+			if a.ReturnError {
+				return nil, mgo.ErrNotFound
+			}
+			return nil, nil
+		}
+		if err = bson.Unmarshal(rev.Snapshot, &res); err != nil {
+			return nil, err
+		}
+	} else if errors.Is(err, mongo.ErrNoDocuments) {
+		ok, er1 := mgo.FindOne(ctx, a.Collection, current, &res)
+		if er1 != nil {
+			return nil, er1
+		}
+		if !ok {
+			if a.ReturnError {
+				return nil, mgo.ErrNotFound
+			}
+			return nil, nil
+		}
+	} else {
+		return nil, err
+	}
+	if a.Mapper != nil {
+		a.Mapper.DbToModel(&res)
+	}
+	return &res, nil
+}
@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"fmt"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	mgo "github.com/core-go/mongo"
+)
+
+type key struct {
+	kIndex int
+	tIndex int
+	json   string
+	bson   string
+}
+
+// SetIdStrategy sets the strategy to generate the ids of new models: mgo.IdDefault, mgo.IdObjectId or mgo.IdUUID
+func (a *Repository[T, K]) SetIdStrategy(strategy string) {
+	a.idStrategy = strategy
+}
+
+// SetCompositeKey maps K, which must be a struct, to several fields of T, instead of _id. The fields of K and T are matched by name.
+// If the composite key is stored as an _id subdocument, SetCompositeKey is not needed.
+func (a *Repository[T, K]) SetCompositeKey() {
+	var t T
+	var k K
+	modelType := reflect.TypeOf(t)
+	keyType := reflect.TypeOf(k)
+	if keyType.Kind() != reflect.Struct {
+		panic("K must be a struct")
+	}
+	keys := make([]key, 0)
+	for i := 0; i < keyType.NumField(); i++ {
+		name := keyType.Field(i).Name
+		index, jsonName, bsonName := FindFieldByName(modelType, name)
+		if index < 0 {
+			panic(modelType.Name() + " struct does not have field " + name)
+		}
+		if modelType.Field(index).Type != keyType.Field(i).Type {
+			panic(name + " of " + modelType.Name() + " and " + keyType.Name() + " must have the same type")
+		}
+		keys = append(keys, key{kIndex: i, tIndex: index, json: jsonName, bson: bsonName})
+	}
+	a.keys = keys
+}
+
+// keyFilter returns the filter by id, or by the fields of the composite key
+func (a *Repository[T, K]) keyFilter(id interface{}) bson.D {
+	if a.keys == nil {
+		return bson.D{{Key: "_id", Value: id}}
+	}
+	filter := bson.D{}
+	kv := reflect.ValueOf(id)
+	for _, k := range a.keys {
+		filter = append(filter, bson.E{Key: k.bson, Value: kv.Field(k.kIndex).Interface()})
+	}
+	return filter
+}
+func (a *Repository[T, K]) keyMap(id interface{}) bson.M {
+	m := bson.M{}
+	for _, e := range a.keyFilter(id) {
+		m[e.Key] = e.Value
+	}
+	return m
+}
+
+// modelId returns the id of the model, which is K for the composite key
+func (a *Repository[T, K]) modelId(vo reflect.Value) interface{} {
+	if a.keys == nil {
+		return vo.Field(a.idIndex).Interface()
+	}
+	var k K
+	kv := reflect.ValueOf(&k).Elem()
+	for _, x := range a.keys {
+		kv.Field(x.kIndex).Set(vo.Field(x.tIndex))
+	}
+	return k
+}
+
+// mapId returns the id of the json map of patch, which is converted to the stored id, such as a hex string to ObjectID
+func (a *Repository[T, K]) mapId(model map[string]interface{}) (interface{}, error) {
+	if a.keys == nil {
+		id, exist := model[a.idJson]
+		if !exist {
+			return nil, fmt.Errorf("%s must be in map[string]interface{} for patch", a.idJson)
+		}
+		id, err := mgo.MapId[K](id, a.ObjectId)
+		if err != nil {
+			return nil, err
+		}
+		model[a.idJson] = id
+		return id, nil
+	}
+	var k K
+	kv := reflect.ValueOf(&k).Elem()
+	for _, x := range a.keys {
+		v, exist := model[x.json]
+		if !exist || v == nil {
+			return nil, fmt.Errorf("%s must be in map[string]interface{} for patch", x.json)
+		}
+		f := kv.Field(x.kIndex)
+		if s, ok := v.(string); ok {
+			switch f.Interface().(type) {
+			case primitive.ObjectID:
+				oid, err := primitive.ObjectIDFromHex(s)
+				if err != nil {
+					return nil, err
+				}
+				v = oid
+			case mgo.UUID:
+				u, err := mgo.ParseUUID(s)
+				if err != nil {
+					return nil, err
+				}
+				v = u
+			}
+			model[x.json] = v
+		}
+		rv := reflect.ValueOf(v)
+		if !rv.Type().ConvertibleTo(f.Type()) {
+			return nil, fmt.Errorf("%s must be %s", x.json, f.Type().String())
+		}
+		f.Set(rv.Convert(f.Type()))
+	}
+	return k, nil
+}
+
+// generateId generates the id of the new model, by the id type and the id strategy
+func (a *Repository[T, K]) generateId(vo reflect.Value) {
+	if a.idIndex < 0 {
+		return
+	}
+	f := vo.Field(a.idIndex)
+	if !mgo.IsEmptyId(f.Interface()) {
+		return
+	}
+	if id, ok := mgo.NewId(f.Type(), a.idStrategy); ok {
+		f.Set(reflect.ValueOf(id))
+	}
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	mgo "github.com/core-go/mongo"
+)
+
+// SetPopulate turns on the population of the fields tagged `ref:"collection"` in All, Load, LoadMany, Search, SearchWithCursor and FindBy.
+// The referenced documents are loaded by one $in query per reference, in the database of the repository. The references tagged "scoped" have the conditions of tenant and soft delete of the repository,
+// and the others are not scoped, unless SetRef sets their scope. With mgo.TenantRouter, the repository of each tenant loads the references in the database of the tenant. See mgo.Ref for the target fields.
+func (a *Repository[T, K]) SetPopulate() {
+	var t T
+	modelType := reflect.TypeOf(t)
+	refs := mgo.FindRefs(modelType)
+	if len(refs) == 0 {
+		panic(modelType.Name() + " struct does not have any fields with ref tag")
+	}
+	for i := range refs {
+		if refs[i].Scoped {
+			refs[i].Scope = a.ScopeFilter
+		}
+	}
+	a.refs = refs
+}
+
+// SetRef sets the scope and the mapper of the referenced documents of the field, which has the ref tag, such as by the repository of the referenced collection:
+// orderRepo.SetRef("CustomerId", customerRepo.ScopeFilter, RefMapper[Customer](customerRepo.Mapper)). scope and dbToModel can be nil. SetPopulate must be called first.
+func (a *Repository[T, K]) SetRef(field string, scope func(ctx context.Context, filter bson.D) (bson.D, error), dbToModel func(model interface{})) {
+	var t T
+	modelType := reflect.TypeOf(t)
+	sf, ok := modelType.FieldByName(field)
+	if ok {
+		for i := range a.refs {
+			if len(sf.Index) == 1 && a.refs[i].Index == sf.Index[0] {
+				a.refs[i].Scope = scope
+				a.refs[i].DbToModel = dbToModel
+				return
+			}
+		}
+	}
+	panic(modelType.Name() + "." + field + " is not a populated field with ref tag")
+}
+
+// RefMapper adapts the mapper of the referenced model to mgo.Ref.DbToModel. It returns nil if mapper is nil.
+func RefMapper[R any](mapper Mapper[R]) func(model interface{}) {
+	if mapper == nil {
+		return nil
+	}
+	return func(model interface{}) {
+		if m, ok := model.(*R); ok {
+			mapper.DbToModel(m)
+		}
+	}
+}
+
+// afterLoad populates the references, then runs the AfterLoad hooks
+func (a *Repository[T, K]) afterLoad(ctx context.Context, objs []T) error {
+	if err := a.populate(ctx, objs); err != nil {
+		return err
+	}
+	return mgo.RunAfterLoads(ctx, a.Hooks, objs)
+}
+func (a *Repository[T, K]) afterLoadOne(ctx context.Context, model *T) error {
+	if len(a.refs) > 0 {
+		objs := []T{*model}
+		if err := a.populate(ctx, objs); err != nil {
+			return err
+		}
+		*model = objs[0]
+	}
+	return mgo.RunAfterLoad(ctx, a.Hooks, model)
+}
+func (a *Repository[T, K]) populate(ctx context.Context, objs []T) error {
+	if len(a.refs) == 0 {
+		return nil
+	}
+	return mgo.Populate(ctx, a.Collection.Database(), objs, a.refs)
+}
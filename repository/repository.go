@@ -29,6 +29,19 @@ type Repository[T any, K any] struct {
 	versionJson  string
 	versionBson  string
 	Mapper       Mapper[T]
+	// ReturnError makes the methods return ErrNotFound, ErrDuplicateKey and ErrVersionConflict, instead of nil, 0 and -1
+	ReturnError bool
+	Hooks       *mgo.Hooks[T]
+	deleteIndex int
+	deleteBson  string
+	deleteFlag  bool
+	audit       *audit
+	history     *history
+	tenant      *mgo.Tenant
+	refs        []mgo.Ref
+	idStrategy  string
+	keys        []key
+	mgo.Options
 }
 
 func FindFieldByName(modelType reflect.Type, fieldName string) (int, string, string) {
@@ -66,7 +79,8 @@ func NewMongoRepositoryWithVersion[T any, K any](db *mongo.Database, collectionN
 		log.Println(modelType.Name() + " Repository can't use functions that need Id value (Ex Load, Exist, Save, Update) because don't have any fields of " + modelType.Name() + " struct define _id bson tag.")
 	}
 	repo := &Repository[T, K]{Collection: db.Collection(collectionName), idJson: jsonIdName, idIndex: idIndex, ObjectId: idObjectId,
-		Map: mgo.MakeBsonMap(modelType), Mapper: mapper, versionIndex: -1}
+		Map: mgo.MakeBsonMap(modelType), Mapper: mapper, versionIndex: -1, deleteIndex: -1}
+	repo.Options = mgo.NewOptions(&repo.Collection)
 	if len(versionField) > 0 {
 		index, versionJson, versionBson := FindFieldByName(modelType, versionField)
 		if index >= 0 {
@@ -84,7 +98,16 @@ func NewRepository[T any, K any](db *mongo.Database, collectionName string, opti
 	return NewMongoRepositoryWithVersion[T, K](db, collectionName, false, "", options...)
 }
 func (a *Repository[T, K]) All(ctx context.Context) ([]T, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, a.Timeout())
+	defer cancel()
 	filter := bson.M{}
+	if a.deleteIndex >= 0 {
+		filter[a.deleteBson] = a.notDeleted()
+	}
+	filter, err := a.scopeMap(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
 	cursor, err := a.Collection.Find(ctx, filter)
 	if err != nil {
 		return nil, err
@@ -100,41 +123,118 @@ func (a *Repository[T, K]) All(ctx context.Context) ([]T, error) {
 			a.Mapper.DbToModel(&objs[i])
 		}
 	}
+	if err = a.afterLoad(ctx, objs); err != nil {
+		return nil, err
+	}
 	return objs, nil
 }
 func (a *Repository[T, K]) Load(ctx context.Context, id K) (*T, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, a.Timeout())
+	defer cancel()
 	var res T
 	if a.ObjectId {
-		objId := fmt.Sprintf("%v", id)
-		objectId, err := primitive.ObjectIDFromHex(objId)
+		objectId, err := a.toId(id)
+		if err != nil {
+			return nil, err
+		}
+		query := a.keyMap(objectId)
+		if a.deleteIndex >= 0 {
+			query[a.deleteBson] = a.notDeleted()
+		}
+		query, err = a.scopeMap(ctx, query)
 		if err != nil {
 			return nil, err
 		}
-		query := bson.M{"_id": objectId}
 		ok, er0 := mgo.FindOne(ctx, a.Collection, query, &res)
+		if !ok && er0 == nil && a.ReturnError {
+			return nil, mgo.ErrNotFound
+		}
 		if ok && er0 == nil && a.Mapper != nil {
 			a.Mapper.DbToModel(&res)
 		}
+		if ok && er0 == nil {
+			if er1 := a.afterLoadOne(ctx, &res); er1 != nil {
+				return nil, er1
+			}
+		}
 		return &res, er0
 	}
-	query := bson.M{"_id": id}
+	query := a.keyMap(id)
+	if a.deleteIndex >= 0 {
+		query[a.deleteBson] = a.notDeleted()
+	}
+	query, er1 := a.scopeMap(ctx, query)
+	if er1 != nil {
+		return nil, er1
+	}
 	ok, er2 := mgo.FindOne(ctx, a.Collection, query, &res)
 	if er2 != nil {
 		return nil, er2
 	}
 	if !ok {
+		if a.ReturnError {
+			return nil, mgo.ErrNotFound
+		}
 		return nil, nil
 	}
 	if a.Mapper != nil {
 		a.Mapper.DbToModel(&res)
 	}
+	if er3 := a.afterLoadOne(ctx, &res); er3 != nil {
+		return nil, er3
+	}
 	return &res, er2
 }
 
+// LoadMany loads the models in the order of ids, and returns the ids which are not found
+func (a *Repository[T, K]) LoadMany(ctx context.Context, ids []K) ([]T, []K, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, a.Timeout())
+	defer cancel()
+	if a.keys != nil {
+		return nil, nil, errors.New("LoadMany does not support composite keys of several fields")
+	}
+	var query bson.M
+	if a.deleteIndex >= 0 {
+		query = bson.M{a.deleteBson: a.notDeleted()}
+	}
+	query, err := a.scopeMap(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	objs, missing, err := mgo.LoadMany[T, K](ctx, a.Collection, ids, a.idIndex, a.ObjectId, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	if a.Mapper != nil {
+		l := len(objs)
+		for i := 0; i < l; i++ {
+			a.Mapper.DbToModel(&objs[i])
+		}
+	}
+	if err = a.afterLoad(ctx, objs); err != nil {
+		return nil, nil, err
+	}
+	return objs, missing, nil
+}
 func (a *Repository[T, K]) Exist(ctx context.Context, id K) (bool, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, a.Timeout())
+	defer cancel()
+	if a.deleteIndex >= 0 || a.tenant != nil || a.keys != nil {
+		oid, err := a.toId(id)
+		if err != nil {
+			return false, err
+		}
+		filter, err := a.idFilter(ctx, oid)
+		if err != nil {
+			return false, err
+		}
+		if a.deleteIndex >= 0 {
+			filter = append(filter, bson.E{Key: a.deleteBson, Value: a.notDeleted()})
+		}
+		return mgo.ExistByFilter(ctx, a.Collection, filter)
+	}
 	if a.ObjectId {
-		objId := fmt.Sprintf("%v", id)
-		objectId, err := primitive.ObjectIDFromHex(objId)
+		objectId, err := a.toId(id)
 		if err != nil {
 			return false, err
 		}
@@ -143,18 +243,41 @@ func (a *Repository[T, K]) Exist(ctx context.Context, id K) (bool, error) {
 	return mgo.Exist(ctx, a.Collection, id)
 }
 func (a *Repository[T, K]) Create(ctx context.Context, model *T) (int64, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, a.Timeout())
+	defer cancel()
+	if err := mgo.RunBeforeCreate(ctx, a.Hooks, model); err != nil {
+		return 0, err
+	}
+	res, err := a.inHistory(ctx, keep(model), func(ctx context.Context) (int64, error) { return a.create(ctx, model) })
+	if err == nil && res > 0 {
+		if er1 := mgo.RunAfterCreate(ctx, a.Hooks, model); er1 != nil {
+			return res, er1
+		}
+	}
+	return res, err
+}
+func (a *Repository[T, K]) create(ctx context.Context, model *T) (int64, error) {
+	if err := a.stamp(ctx, model); err != nil {
+		return 0, err
+	}
 	if a.Mapper != nil {
-		a.Mapper.ModelToDb(model)
+		if err := mgo.ModelToDb[T](a.Mapper, model); err != nil {
+			return 0, err
+		}
 	}
 	vo := reflect.Indirect(reflect.ValueOf(model))
 	if a.versionIndex >= 0 {
 		setVersion(vo, a.versionIndex)
 	}
-	rid, res, err := mgo.InsertOne(ctx, a.Collection, model)
+	if a.audit != nil {
+		a.audit.create(ctx, vo, mgo.Now())
+	}
+	a.generateId(vo)
+	rid, res, err := a.insertOne(ctx, model, nil)
 	if err != nil {
 		return res, err
 	}
-	if rid != nil {
+	if rid != nil && a.idIndex >= 0 {
 		idF := vo.Field(a.idIndex)
 		switch idF.Kind() {
 		case reflect.String:
@@ -171,79 +294,204 @@ func (a *Repository[T, K]) Create(ctx context.Context, model *T) (int64, error)
 		default:
 		}
 	}
-	return res, err
+	return a.recordCreate(ctx, vo, res, err)
 }
 func (a *Repository[T, K]) Update(ctx context.Context, model *T) (int64, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, a.Timeout())
+	defer cancel()
+	if err := mgo.RunBeforeUpdate(ctx, a.Hooks, model); err != nil {
+		return 0, err
+	}
+	res, err := a.inHistory(ctx, keep(model), func(ctx context.Context) (int64, error) { return a.update(ctx, model, nil) })
+	if err == nil && res > 0 {
+		if er1 := mgo.RunAfterUpdate(ctx, a.Hooks, model); er1 != nil {
+			return res, er1
+		}
+	}
+	return res, err
+}
+func (a *Repository[T, K]) update(ctx context.Context, model *T, out *output[T]) (int64, error) {
+	if err := a.stamp(ctx, model); err != nil {
+		return 0, err
+	}
 	if a.Mapper != nil {
-		a.Mapper.ModelToDb(model)
+		if err := mgo.ModelToDb[T](a.Mapper, model); err != nil {
+			return 0, err
+		}
 	}
 	vo := reflect.Indirect(reflect.ValueOf(model))
-	id := vo.Field(a.idIndex).Interface()
+	id := a.modelId(vo)
+	var currentVersion interface{}
 	if a.versionIndex >= 0 {
-		currentVersion := vo.Field(a.versionIndex).Interface()
+		currentVersion = vo.Field(a.versionIndex).Interface()
 		increaseVersion(vo, a.versionIndex, currentVersion)
-		var filter = bson.D{}
-		filter = append(filter, bson.E{Key: "_id", Value: id})
+	}
+	var doc interface{} = model
+	if a.audit != nil {
+		a.audit.update(ctx, vo, mgo.Now())
+		if a.audit.hasCreated() {
+			set, _, err := a.audit.split(model)
+			if err != nil {
+				return 0, err
+			}
+			doc = set
+		}
+	}
+	prev, err := a.snapshot(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	filter, err := a.writeFilter(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	if a.versionIndex >= 0 {
 		filter = append(filter, bson.E{Key: a.versionBson, Value: currentVersion})
-		res, err := mgo.UpdateOneByFilter(ctx, a.Collection, filter, model)
+		res, err := a.updateOne(ctx, filter, doc, out)
 		if err != nil {
 			return res, err
 		}
 		if res <= 0 {
-			ok, _ := mgo.Exist(ctx, a.Collection, id)
-			if ok {
-				return -1, nil
-			} else {
-				return 0, nil
-			}
+			return a.conflict(ctx, id)
 		}
+		return a.record(ctx, OpUpdate, id, prev, doc, res, err)
 	}
-	return mgo.UpdateOne(ctx, a.Collection, id, model)
+	res, err := a.updateOne(ctx, filter, doc, out)
+	res, err = a.record(ctx, OpUpdate, id, prev, doc, res, err)
+	return a.notFound(res, err)
 }
 
 func (a *Repository[T, K]) Patch(ctx context.Context, model map[string]interface{}) (int64, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, a.Timeout())
+	defer cancel()
+	if err := mgo.RunBeforePatch(ctx, a.Hooks, model); err != nil {
+		return 0, err
+	}
+	return a.inHistory(ctx, keepMap(model), func(ctx context.Context) (int64, error) { return a.patch(ctx, model, nil) })
+}
+func (a *Repository[T, K]) patch(ctx context.Context, model map[string]interface{}, out *output[T]) (int64, error) {
+	if a.tenant != nil {
+		if err := a.tenant.StampMap(ctx, model); err != nil {
+			return 0, err
+		}
+	}
 	if a.Mapper != nil {
-		model = a.Mapper.MapToDb(model)
+		m, err := mgo.MapToDb(a.Mapper, model)
+		if err != nil {
+			return 0, err
+		}
+		model = m
 	}
-	id, exist := model[a.idJson]
-	if !exist {
-		return -1, fmt.Errorf("%s must be in map[string]interface{} for patch", a.idJson)
+	id, err := a.mapId(model)
+	if err != nil {
+		return -1, err
 	}
 	if a.versionIndex >= 0 {
 		currentVersion, vok := model[a.versionJson]
 		if !vok {
 			return -1, fmt.Errorf("%s must be in model for patch", a.versionJson)
 		}
-		ok := increaseMapVersion(model, a.versionJson, currentVersion)
-		if !ok {
+		var t T
+		versionType := reflect.TypeOf(t).Field(a.versionIndex).Type
+		currentVersion, vok = increaseMapVersion(model, a.versionJson, versionType, currentVersion)
+		if !vok {
 			return -1, errors.New("do not support this version type")
 		}
-		var filter = bson.D{}
-		filter = append(filter, bson.E{Key: "_id", Value: id})
+		filter, err := a.writeFilter(ctx, id)
+		if err != nil {
+			return 0, err
+		}
 		filter = append(filter, bson.E{Key: a.versionBson, Value: currentVersion})
+		if a.audit != nil {
+			a.audit.patch(ctx, model, mgo.Now())
+		}
 		b := mgo.MapToBson(model, a.Map)
-		return mgo.PatchOneByFilter(ctx, a.Collection, filter, b)
+		prev, err := a.snapshot(ctx, id)
+		if err != nil {
+			return 0, err
+		}
+		res, err := a.updateOne(ctx, filter, b, out)
+		if err == nil && res <= 0 && a.ReturnError {
+			return a.conflict(ctx, id)
+		}
+		return a.record(ctx, OpPatch, id, prev, b, res, err)
+	}
+	if a.audit != nil {
+		a.audit.patch(ctx, model, mgo.Now())
 	}
 	b := mgo.MapToBson(model, a.Map)
-	return mgo.PatchOne(ctx, a.Collection, id, b)
+	filter, err := a.writeFilter(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	prev, err := a.snapshot(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	res, err := a.updateOne(ctx, filter, b, out)
+	res, err = a.record(ctx, OpPatch, id, prev, b, res, err)
+	return a.notFound(res, err)
 }
 
 func (a *Repository[T, K]) Save(ctx context.Context, model *T) (int64, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, a.Timeout())
+	defer cancel()
+	isNew := a.isNew(model)
+	var err error
+	if isNew {
+		err = mgo.RunBeforeCreate(ctx, a.Hooks, model)
+	} else {
+		err = mgo.RunBeforeUpdate(ctx, a.Hooks, model)
+	}
+	if err != nil {
+		return 0, err
+	}
+	res, err := a.inHistory(ctx, keep(model), func(ctx context.Context) (int64, error) { return a.save(ctx, model, nil) })
+	if err == nil && res > 0 {
+		if isNew {
+			err = mgo.RunAfterCreate(ctx, a.Hooks, model)
+		} else {
+			err = mgo.RunAfterUpdate(ctx, a.Hooks, model)
+		}
+	}
+	return res, err
+}
+
+// isNew returns true if the id is empty. The composite keys are never new, because they are set by the caller.
+func (a *Repository[T, K]) isNew(model *T) bool {
+	if a.keys != nil || a.idIndex < 0 {
+		return false
+	}
+	f := reflect.Indirect(reflect.ValueOf(model)).Field(a.idIndex)
+	if mgo.IsCompositeId(f.Type()) {
+		return false
+	}
+	return mgo.IsEmptyId(f.Interface())
+}
+func (a *Repository[T, K]) save(ctx context.Context, model *T, out *output[T]) (int64, error) {
+	if err := a.stamp(ctx, model); err != nil {
+		return 0, err
+	}
 	if a.Mapper != nil {
-		a.Mapper.ModelToDb(model)
+		if err := mgo.ModelToDb[T](a.Mapper, model); err != nil {
+			return 0, err
+		}
 	}
 	vo := reflect.Indirect(reflect.ValueOf(model))
-	id := vo.Field(a.idIndex).Interface()
-	sid, ok := id.(string)
-	if id == nil || ok && len(sid) == 0 {
+	id := a.modelId(vo)
+	if a.isNew(model) {
 		if a.versionIndex >= 0 {
 			setVersion(vo, a.versionIndex)
 		}
-		rid, res, err := mgo.InsertOne(ctx, a.Collection, model)
+		if a.audit != nil {
+			a.audit.create(ctx, vo, mgo.Now())
+		}
+		a.generateId(vo)
+		rid, res, err := a.insertOne(ctx, model, out)
 		if err != nil {
 			return res, err
 		}
-		if rid != nil {
+		if rid != nil && a.idIndex >= 0 {
 			idF := vo.Field(a.idIndex)
 			switch idF.Kind() {
 			case reflect.String:
@@ -260,78 +508,210 @@ func (a *Repository[T, K]) Save(ctx context.Context, model *T) (int64, error) {
 			default:
 			}
 		}
-		return res, err
+		return a.recordCreate(ctx, vo, res, err)
 	} else {
+		filter, err := a.writeFilter(ctx, id)
+		if err != nil {
+			return 0, err
+		}
 		if a.versionIndex >= 0 {
 			currentVersion := vo.Field(a.versionIndex).Interface()
 			increaseVersion(vo, a.versionIndex, currentVersion)
-			var filter = bson.D{}
-			filter = append(filter, bson.E{Key: "_id", Value: id})
 			filter = append(filter, bson.E{Key: a.versionBson, Value: currentVersion})
-			return mgo.UpsertOneByFilter(ctx, a.Collection, filter, model)
-		} else {
-			return mgo.UpsertOne(ctx, a.Collection, id, model)
 		}
+		prev, err := a.snapshot(ctx, id)
+		if err != nil {
+			return 0, err
+		}
+		if a.audit != nil {
+			original := reflect.New(vo.Type()).Elem()
+			original.Set(vo)
+			a.audit.create(ctx, vo, mgo.Now())
+			if a.audit.hasCreated() {
+				set, onInsert, err := a.audit.split(model)
+				if err != nil {
+					return 0, err
+				}
+				// the created fields are kept by $setOnInsert, so the model has the new values only if the document is inserted
+				stamped := reflect.New(vo.Type()).Elem()
+				stamped.Set(vo)
+				a.audit.copyCreated(vo, original)
+				var insert interface{}
+				if len(onInsert) > 0 {
+					insert = onInsert
+				}
+				res, inserted, err := a.upsert(ctx, id, filter, set, insert, out)
+				if inserted {
+					a.audit.copyCreated(vo, stamped)
+				}
+				if a.versionIndex >= 0 {
+					res, err = a.versionError(res, err)
+				}
+				return a.record(ctx, OpSave, id, prev, set, res, err)
+			}
+		}
+		res, _, err := a.upsert(ctx, id, filter, model, nil, out)
+		if a.versionIndex >= 0 {
+			res, err = a.versionError(res, err)
+		}
+		return a.record(ctx, OpSave, id, prev, model, res, err)
 	}
 }
 func (a *Repository[T, K]) Delete(ctx context.Context, id K) (int64, error) {
-	if a.ObjectId {
-		objId := fmt.Sprintf("%v", id)
-		objectId, err := primitive.ObjectIDFromHex(objId)
+	ctx, cancel := mgo.WithTimeout(ctx, a.Timeout())
+	defer cancel()
+	if err := mgo.RunBeforeDelete[T](ctx, a.Hooks, id); err != nil {
+		return 0, err
+	}
+	return a.inHistory(ctx, nil, func(ctx context.Context) (int64, error) { return a.delete(ctx, id) })
+}
+func (a *Repository[T, K]) delete(ctx context.Context, id K) (int64, error) {
+	oid, err := a.toId(id)
+	if err != nil {
+		return 0, err
+	}
+	filter, err := a.idFilter(ctx, oid)
+	if err != nil {
+		return 0, err
+	}
+	prev, err := a.snapshot(ctx, oid)
+	if err != nil {
+		return 0, err
+	}
+	if a.deleteIndex >= 0 {
+		res, err := a.softDelete(ctx, id)
+		res, err = a.record(ctx, OpDelete, oid, prev, nil, res, err)
+		return a.notFound(res, err)
+	}
+	res, err := mgo.DeleteOneByFilter(ctx, a.Collection, filter)
+	res, err = a.record(ctx, OpDelete, oid, prev, nil, res, err)
+	return a.notFound(res, err)
+}
+func (a *Repository[T, K]) toId(id K) (interface{}, error) {
+	return mgo.ToId(id, a.ObjectId)
+}
+
+// GetVersion returns the version of the model, which can be sent in ETag http header by mgo.ETag
+func (a *Repository[T, K]) GetVersion(model *T) (interface{}, bool) {
+	if a.versionIndex < 0 || model == nil {
+		return nil, false
+	}
+	return reflect.ValueOf(model).Elem().Field(a.versionIndex).Interface(), true
+}
+func (a *Repository[T, K]) insertOne(ctx context.Context, model *T, out *output[T]) (*primitive.ObjectID, int64, error) {
+	if out != nil {
+		var doc T
+		rid, res, err := mgo.InsertOneAndGet(ctx, a.Collection, model, &doc)
 		if err != nil {
-			return 0, err
+			if _, ok := err.(*mgo.DuplicateKeyError); ok && !a.ReturnError {
+				return nil, 0, nil
+			}
+			return nil, 0, err
 		}
-		return mgo.DeleteOne(ctx, a.Collection, objectId)
+		out.doc = &doc
+		out.insert = true
+		return rid, res, nil
+	}
+	if a.ReturnError {
+		return mgo.InsertOneWithError(ctx, a.Collection, model)
+	}
+	return mgo.InsertOne(ctx, a.Collection, model)
+}
+func (a *Repository[T, K]) snapshot(ctx context.Context, id interface{}) (bson.Raw, error) {
+	if a.history == nil {
+		return nil, nil
+	}
+	filter, err := a.idFilter(ctx, id)
+	if err != nil {
+		return nil, err
 	}
-	return mgo.DeleteOne(ctx, a.Collection, id)
+	return a.history.snapshot(ctx, a.Collection, filter)
 }
 
+// record writes the revision to the history collection after the write succeeds
+func (a *Repository[T, K]) record(ctx context.Context, op string, id interface{}, prev bson.Raw, doc interface{}, res int64, err error) (int64, error) {
+	if err != nil || res <= 0 || a.history == nil {
+		return res, err
+	}
+	if er1 := a.history.record(ctx, op, id, a.tenantId(ctx), prev, doc); er1 != nil {
+		return res, er1
+	}
+	return res, err
+}
+func (a *Repository[T, K]) notFound(res int64, err error) (int64, error) {
+	if err == nil && res <= 0 && a.ReturnError {
+		return 0, mgo.ErrNotFound
+	}
+	return res, err
+}
+
+// upsert fails with a duplicate key on _id when the document is soft-deleted, or when the version does not match
+func (a *Repository[T, K]) upsert(ctx context.Context, id interface{}, filter bson.D, set interface{}, onInsert interface{}, out *output[T]) (int64, bool, error) {
+	res, inserted, err := a.upsertOne(ctx, filter, set, onInsert, out)
+	if dup, ok := mgo.ToDuplicateKeyError(err); ok && a.deleteIndex >= 0 && dup.Index == "_id_" {
+		res, err = a.conflict(ctx, id)
+		return res, false, err
+	}
+	return res, inserted, err
+}
+
+// conflict is called when the filter by id and version matches no document
+func (a *Repository[T, K]) conflict(ctx context.Context, id interface{}) (int64, error) {
+	filter, err := a.writeFilter(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	ok, err := mgo.ExistByFilter(ctx, a.Collection, filter)
+	if !a.ReturnError {
+		if ok {
+			return -1, nil
+		}
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if ok {
+		return 0, mgo.ErrVersionConflict
+	}
+	return 0, mgo.ErrNotFound
+}
+
+// versionError is called after an upsert by id and version, which fails with a duplicate key on _id when the version does not match
+func (a *Repository[T, K]) versionError(res int64, err error) (int64, error) {
+	if dup, ok := mgo.ToDuplicateKeyError(err); ok && a.ReturnError {
+		if dup.Index == "_id_" {
+			return 0, mgo.ErrVersionConflict
+		}
+		return 0, dup
+	}
+	return res, err
+}
 func setVersion(vo reflect.Value, versionIndex int) bool {
-	versionType := vo.Field(versionIndex).Type().String()
-	switch versionType {
-	case "int32":
-		vo.Field(versionIndex).Set(reflect.ValueOf(int32(1)))
-		return true
-	case "int":
-		vo.Field(versionIndex).Set(reflect.ValueOf(1))
-		return true
-	case "int64":
-		vo.Field(versionIndex).Set(reflect.ValueOf(int64(1)))
-		return true
-	default:
-		return false
+	v, ok := mgo.NewVersion(vo.Field(versionIndex).Type())
+	if ok {
+		vo.Field(versionIndex).Set(reflect.ValueOf(v))
 	}
+	return ok
 }
 func increaseVersion(vo reflect.Value, versionIndex int, curVer interface{}) bool {
-	versionType := vo.Field(versionIndex).Type().String()
-	switch versionType {
-	case "int32":
-		nextVer := curVer.(int32) + 1
-		vo.Field(versionIndex).Set(reflect.ValueOf(nextVer))
-		return true
-	case "int":
-		nextVer := curVer.(int) + 1
-		vo.Field(versionIndex).Set(reflect.ValueOf(nextVer))
-		return true
-	case "int64":
-		nextVer := curVer.(int64) + 1
-		vo.Field(versionIndex).Set(reflect.ValueOf(nextVer))
-		return true
-	default:
-		return false
+	v, ok := mgo.NextVersion(vo.Field(versionIndex).Type(), curVer)
+	if ok {
+		vo.Field(versionIndex).Set(reflect.ValueOf(v))
 	}
+	return ok
 }
-func increaseMapVersion(model map[string]interface{}, name string, currentVersion interface{}) bool {
-	if versionI32, ok := currentVersion.(int32); ok {
-		model[name] = versionI32 + 1
-		return true
-	} else if versionI, ok := currentVersion.(int); ok {
-		model[name] = versionI + 1
-		return true
-	} else if versionI64, ok := currentVersion.(int64); ok {
-		model[name] = versionI64 + 1
-		return true
-	} else {
-		return false
+
+// increaseMapVersion converts the current version of the map to the version type, and sets the next version to the map
+func increaseMapVersion(model map[string]interface{}, name string, versionType reflect.Type, currentVersion interface{}) (interface{}, bool) {
+	current, ok := mgo.ToVersion(versionType, currentVersion)
+	if !ok {
+		return nil, false
+	}
+	next, ok := mgo.NextVersion(versionType, current)
+	if !ok {
+		return nil, false
 	}
+	model[name] = next
+	return current, true
 }
@@ -16,6 +16,7 @@ type SearchRepository[T any, K any, F any] struct {
 	GetSort    func(m interface{}) string
 	BuildSort  func(s string, modelType reflect.Type) bson.D
 	ModelType  reflect.Type
+	search     mgo.SearchOptions
 }
 
 func NewSearchRepositoryWithSortAndVersion[T any, K any, F any](db *mongo.Database, collectionName string, buildQuery func(m F) (bson.D, bson.M), getSort func(interface{}) string, buildSort func(string, reflect.Type) bson.D, idObjectId bool, versionField string, options ...Mapper[T]) *SearchRepository[T, K, F] {
@@ -38,8 +39,17 @@ func NewSearchRepository[T any, K any, F any](db *mongo.Database, collectionName
 	return &SearchRepository[T, K, F]{Repository: repo, BuildSort: mgo.BuildSort, GetSort: getSort, BuildQuery: buildQuery, ModelType: modelType}
 }
 func (b *SearchRepository[T, K, F]) Search(ctx context.Context, m F, limit int64, skip int64) ([]T, int64, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, b.Timeout())
+	defer cancel()
 	var objs []T
 	query, fields := b.BuildQuery(m)
+	if b.deleteIndex >= 0 {
+		query = append(query, bson.E{Key: b.deleteBson, Value: b.notDeleted()})
+	}
+	query, err := b.scope(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
 
 	var sort = bson.D{}
 	s := b.GetSort(m)
@@ -48,13 +58,46 @@ func (b *SearchRepository[T, K, F]) Search(ctx context.Context, m F, limit int64
 		skip = 0
 	}
 	var total int64
-	var err error
-	total, err = mgo.BuildSearchResult(ctx, b.Collection, &objs, query, fields, sort, limit, skip)
+	total, err = mgo.BuildSearchResult(ctx, b.searchCollection(), &objs, query, fields, sort, limit, skip)
 	if b.Mapper != nil {
 		l := len(objs)
 		for i := 0; i < l; i++ {
 			b.Mapper.DbToModel(&objs[i])
 		}
 	}
+	if err == nil {
+		err = b.afterLoad(ctx, objs)
+	}
 	return objs, total, err
 }
+
+// SearchWithCursor is the keyset pagination of Search, which returns the token of the next page
+func (b *SearchRepository[T, K, F]) SearchWithCursor(ctx context.Context, m F, limit int64, nextToken string) ([]T, string, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, b.Timeout())
+	defer cancel()
+	var objs []T
+	query, fields := b.BuildQuery(m)
+	if b.deleteIndex >= 0 {
+		query = append(query, bson.E{Key: b.deleteBson, Value: b.notDeleted()})
+	}
+	query, err := b.scope(ctx, query)
+	if err != nil {
+		return nil, "", err
+	}
+	s := b.GetSort(m)
+	sort := b.BuildSort(s, b.ModelType)
+	next, err := mgo.BuildSearchResultWithCursor(ctx, b.searchCollection(), &objs, query, fields, sort, limit, nextToken)
+	if err != nil {
+		return nil, "", err
+	}
+	if b.Mapper != nil {
+		l := len(objs)
+		for i := 0; i < l; i++ {
+			b.Mapper.DbToModel(&objs[i])
+		}
+	}
+	if err = b.afterLoad(ctx, objs); err != nil {
+		return nil, "", err
+	}
+	return objs, next, nil
+}
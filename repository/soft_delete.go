@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	mgo "github.com/core-go/mongo"
+)
+
+var (
+	ErrSoftDeleteDisabled = errors.New("soft delete is not enabled")
+	ErrNoDeleteTime       = errors.New("purge needs a time.Time delete field")
+)
+
+func NewRepositoryWithSoftDelete[T any, K any](db *mongo.Database, collectionName string, deleteField string, options ...Mapper[T]) *Repository[T, K] {
+	repo := NewMongoRepositoryWithVersion[T, K](db, collectionName, false, "", options...)
+	repo.SetSoftDelete(deleteField)
+	return repo
+}
+
+// SetSoftDelete turns on soft delete mode. deleteField is the struct field name, which must be bool, *bool, time.Time or *time.Time.
+// Delete sets this field instead of removing the document, and Load, Exist, All and Search skip the soft-deleted documents.
+// The zero time is not deleted, so that time.Time fields without omitempty work.
+func (a *Repository[T, K]) SetSoftDelete(deleteField string) {
+	var t T
+	modelType := reflect.TypeOf(t)
+	index, _, bsonName := FindFieldByName(modelType, deleteField)
+	if index < 0 {
+		panic(modelType.Name() + " struct does not have field " + deleteField)
+	}
+	fieldType := modelType.Field(index).Type
+	if fieldType.Kind() == reflect.Ptr {
+		fieldType = fieldType.Elem()
+	}
+	if fieldType.Kind() == reflect.Bool {
+		a.deleteFlag = true
+	} else if fieldType == reflect.TypeOf(time.Time{}) {
+		a.deleteFlag = false
+	} else {
+		panic(deleteField + " must be bool, *bool, time.Time or *time.Time")
+	}
+	a.deleteIndex = index
+	a.deleteBson = bsonName
+}
+func (a *Repository[T, K]) notDeleted() interface{} {
+	if a.deleteFlag {
+		return bson.M{"$ne": true}
+	}
+	return bson.M{"$in": bson.A{nil, time.Time{}}}
+}
+func (a *Repository[T, K]) deleted() interface{} {
+	if a.deleteFlag {
+		return true
+	}
+	return bson.M{"$gt": time.Time{}}
+}
+
+// writeFilter is the filter by id of Update, Patch and Save, which must not change the soft-deleted documents
+func (a *Repository[T, K]) writeFilter(ctx context.Context, id interface{}) (bson.D, error) {
+	filter, err := a.idFilter(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if a.deleteIndex >= 0 {
+		filter = append(filter, bson.E{Key: a.deleteBson, Value: a.notDeleted()})
+	}
+	return filter, nil
+}
+func (a *Repository[T, K]) softDelete(ctx context.Context, id K) (int64, error) {
+	oid, err := a.toId(id)
+	if err != nil {
+		return 0, err
+	}
+	filter := a.keyMap(oid)
+	filter[a.deleteBson] = a.notDeleted()
+	filter, err = a.scopeMap(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	res, err := a.Collection.UpdateOne(ctx, filter, a.softDeleteUpdate(ctx))
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
+}
+
+// softDeleteUpdate sets the delete field, the updated audit fields and the next version, so that a stale Update fails with a version conflict
+func (a *Repository[T, K]) softDeleteUpdate(ctx context.Context) bson.D {
+	set := a.touch(ctx)
+	if a.deleteFlag {
+		set[a.deleteBson] = true
+	} else {
+		set[a.deleteBson] = mgo.Now()
+	}
+	update := a.nextVersion(set)
+	return append(update, bson.E{Key: "$set", Value: set})
+}
+
+// touch returns the updated audit fields, with bson names
+func (a *Repository[T, K]) touch(ctx context.Context) bson.M {
+	set := bson.M{}
+	if a.audit != nil {
+		if user := a.audit.user(ctx); a.audit.updatedBy.index >= 0 && len(user) > 0 {
+			set[a.audit.updatedBy.bson] = user
+		}
+		if a.audit.updatedAt.index >= 0 {
+			set[a.audit.updatedAt.bson] = mgo.Now()
+		}
+	}
+	return set
+}
+
+// Restore brings back a soft-deleted document
+func (a *Repository[T, K]) Restore(ctx context.Context, id K) (int64, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, a.Timeout())
+	defer cancel()
+	if a.deleteIndex < 0 {
+		return 0, ErrSoftDeleteDisabled
+	}
+	oid, err := a.toId(id)
+	if err != nil {
+		return 0, err
+	}
+	filter := a.keyMap(oid)
+	filter[a.deleteBson] = a.deleted()
+	filter, err = a.scopeMap(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	set := a.touch(ctx)
+	update := a.nextVersion(set)
+	update = append(update, bson.E{Key: "$unset", Value: bson.M{a.deleteBson: ""}})
+	if len(set) > 0 {
+		update = append(update, bson.E{Key: "$set", Value: set})
+	}
+	res, err := a.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+	return a.notFound(res.ModifiedCount, nil)
+}
+
+// Purge removes the documents which were soft-deleted before olderThan. A bool flag has no deletion time, so it returns ErrNoDeleteTime.
+func (a *Repository[T, K]) Purge(ctx context.Context, olderThan time.Time) (int64, error) {
+	ctx, cancel := mgo.WithTimeout(ctx, a.Timeout())
+	defer cancel()
+	if a.deleteIndex < 0 {
+		return 0, ErrSoftDeleteDisabled
+	}
+	if a.deleteFlag {
+		return 0, ErrNoDeleteTime
+	}
+	filter := bson.M{a.deleteBson: bson.M{"$gt": time.Time{}, "$lte": olderThan}}
+	filter, err := a.scopeMap(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	res, err := a.Collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	mgo "github.com/core-go/mongo"
+)
+
+func NewRepositoryWithTenant[T any, K any](db *mongo.Database, collectionName string, tenantKey string, tenantField string, options ...Mapper[T]) *Repository[T, K] {
+	repo := NewMongoRepositoryWithVersion[T, K](db, collectionName, false, "", options...)
+	repo.SetTenant(tenantKey, tenantField)
+	return repo
+}
+
+// SetTenant turns on tenant mode for shared collections. The tenant id is read from ctx.Value(tenantKey), and tenantField is the struct field name of the tenant id.
+// All filters are scoped by the tenant id, and the models are stamped with it. All calls without a tenant in ctx fail with mgo.ErrNoTenant.
+// For database per tenant, use mgo.TenantRouter instead.
+func (a *Repository[T, K]) SetTenant(tenantKey string, tenantField string) {
+	var t T
+	modelType := reflect.TypeOf(t)
+	tenant := mgo.NewTenant(modelType, tenantKey, tenantField)
+	if tenant.Index < 0 {
+		panic(modelType.Name() + " struct does not have field " + tenantField)
+	}
+	a.tenant = tenant
+}
+
+// idFilter returns the filter by id, scoped by the tenant
+func (a *Repository[T, K]) idFilter(ctx context.Context, id interface{}) (bson.D, error) {
+	return a.scope(ctx, a.keyFilter(id))
+}
+func (a *Repository[T, K]) scope(ctx context.Context, filter bson.D) (bson.D, error) {
+	if a.tenant == nil {
+		return filter, nil
+	}
+	return a.tenant.Filter(ctx, filter)
+}
+func (a *Repository[T, K]) scopeMap(ctx context.Context, filter bson.M) (bson.M, error) {
+	if a.tenant == nil {
+		return filter, nil
+	}
+	return a.tenant.FilterMap(ctx, filter)
+}
+func (a *Repository[T, K]) stamp(ctx context.Context, model *T) error {
+	if a.tenant == nil {
+		return nil
+	}
+	return a.tenant.Stamp(ctx, model)
+}
+func (a *Repository[T, K]) tenantId(ctx context.Context) interface{} {
+	if a.tenant == nil {
+		return nil
+	}
+	id, _ := a.tenant.Get(ctx)
+	return id
+}
+
+// TenantKey returns the key of the tenant id in the context, or an empty string if the tenant mode is off, so that cache.NewLoader scopes the cache by the tenant
+func (a *Repository[T, K]) TenantKey() string {
+	if a.tenant == nil {
+		return ""
+	}
+	return a.tenant.Key
+}
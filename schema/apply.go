@@ -0,0 +1,60 @@
+package schema
+
+import (
+	"context"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	LevelStrict   = "strict"
+	LevelModerate = "moderate"
+	LevelOff      = "off"
+	ActionError   = "error"
+	ActionWarn    = "warn"
+)
+
+// Options are the validationLevel and validationAction of the validator. By default, they are "strict" and "error".
+type Options struct {
+	ValidationLevel  string
+	ValidationAction string
+}
+
+// Apply sets the $jsonSchema validator of the collection by collMod, or creates the collection with the validator if it does not exist
+func Apply(ctx context.Context, db *mongo.Database, collectionName string, schema bson.M, opts ...Options) error {
+	var o Options
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if len(o.ValidationLevel) == 0 {
+		o.ValidationLevel = LevelStrict
+	}
+	if len(o.ValidationAction) == 0 {
+		o.ValidationAction = ActionError
+	}
+	validator := bson.M{"$jsonSchema": schema}
+	names, err := db.ListCollectionNames(ctx, bson.M{"name": collectionName})
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		createOpts := options.CreateCollection().SetValidator(validator).SetValidationLevel(o.ValidationLevel).SetValidationAction(o.ValidationAction)
+		return db.CreateCollection(ctx, collectionName, createOpts)
+	}
+	cmd := bson.D{
+		{Key: "collMod", Value: collectionName},
+		{Key: "validator", Value: validator},
+		{Key: "validationLevel", Value: o.ValidationLevel},
+		{Key: "validationAction", Value: o.ValidationAction},
+	}
+	return db.RunCommand(ctx, cmd).Err()
+}
+
+// ApplyModel applies the $jsonSchema of T, which is built by Build
+func ApplyModel[T any](ctx context.Context, db *mongo.Database, collectionName string, opts ...Options) error {
+	var t T
+	return Apply(ctx, db, collectionName, Build(reflect.TypeOf(t)), opts...)
+}
@@ -0,0 +1,256 @@
+package schema
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	mgo "github.com/core-go/mongo"
+)
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	objectIdType   = reflect.TypeOf(primitive.ObjectID{})
+	decimalType    = reflect.TypeOf(primitive.Decimal128{})
+	dateTimeType   = reflect.TypeOf(primitive.DateTime(0))
+	uuidType       = reflect.TypeOf(mgo.UUID{})
+	binaryType     = reflect.TypeOf(primitive.Binary{})
+	bsonRawType    = reflect.TypeOf(bson.Raw{})
+	marshalerTypes = []reflect.Type{reflect.TypeOf((*bson.Marshaler)(nil)).Elem(), reflect.TypeOf((*bson.ValueMarshaler)(nil)).Elem()}
+)
+
+// Build returns the $jsonSchema of the model type. The bson names are the bson tags, or the lower case field names, as the mongo driver.
+// The fields are required, except the pointers, the fields tagged omitempty, and the fields tagged `validate:"omitempty"`.
+// The pointers, slices, maps and interfaces are nullable. These rules of the validate tag are supported: required, min, max, len, gt, gte, lt, lte and oneof.
+// Only required is applied to the fields tagged `encrypt:"true"` or `encrypt:"deterministic"`, because the ciphertexts are stored.
+func Build(modelType reflect.Type) bson.M {
+	if modelType.Kind() == reflect.Ptr {
+		modelType = modelType.Elem()
+	}
+	return buildObject(modelType, map[reflect.Type]bool{})
+}
+func BuildSchema[T any]() bson.M {
+	var t T
+	return Build(reflect.TypeOf(t))
+}
+
+func buildObject(modelType reflect.Type, visited map[reflect.Type]bool) bson.M {
+	if visited[modelType] {
+		return bson.M{"bsonType": "object"}
+	}
+	visited[modelType] = true
+	defer delete(visited, modelType)
+	properties := bson.M{}
+	required := make([]string, 0)
+	buildProperties(properties, &required, modelType, visited)
+	s := bson.M{"bsonType": "object", "properties": properties}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+func buildProperties(properties bson.M, required *[]string, modelType reflect.Type, visited map[reflect.Type]bool) {
+	numField := modelType.NumField()
+	for i := 0; i < numField; i++ {
+		field := modelType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := strings.ToLower(field.Name)
+		omitempty := false
+		inline := false
+		if tag, ok := field.Tag.Lookup("bson"); ok {
+			if tag == "-" {
+				continue
+			}
+			a := strings.Split(tag, ",")
+			if len(a[0]) > 0 {
+				name = a[0]
+			}
+			for _, o := range a[1:] {
+				switch o {
+				case "omitempty":
+					omitempty = true
+				case "inline":
+					inline = true
+				}
+			}
+		}
+		fieldType := field.Type
+		if inline {
+			t := fieldType
+			if t.Kind() == reflect.Ptr {
+				t = t.Elem()
+			}
+			if t.Kind() == reflect.Struct && !visited[t] {
+				visited[t] = true
+				buildProperties(properties, required, t, visited)
+				delete(visited, t)
+			}
+			continue
+		}
+		rules := parseRules(field.Tag.Get("validate"))
+		_, isRequired := rules["required"]
+		_, isOptional := rules["omitempty"]
+		if isRequired || (!omitempty && !isOptional && fieldType.Kind() != reflect.Ptr) {
+			*required = append(*required, name)
+		}
+		if tag := field.Tag.Get("encrypt"); tag == "true" || tag == "deterministic" {
+			// the ciphertext of encrypt.FieldMapper is longer than the value, and is not one of oneof
+			rules = nil
+		}
+		property := buildField(fieldType, rules, visited)
+		if name == "_id" && property["bsonType"] == "string" {
+			// the empty string ids are generated by the server as ObjectID
+			property["bsonType"] = []string{"string", "objectId"}
+		}
+		properties[name] = property
+	}
+}
+func buildField(fieldType reflect.Type, rules map[string]string, visited map[reflect.Type]bool) bson.M {
+	nullable := false
+	switch fieldType.Kind() {
+	case reflect.Ptr:
+		nullable = true
+		fieldType = fieldType.Elem()
+	case reflect.Slice, reflect.Map, reflect.Interface:
+		nullable = true
+	}
+	s := buildType(fieldType, visited)
+	applyRules(s, fieldType, rules)
+	if nullable {
+		if t, ok := s["bsonType"]; ok {
+			switch v := t.(type) {
+			case string:
+				s["bsonType"] = []string{v, "null"}
+			case []string:
+				s["bsonType"] = append(v, "null")
+			}
+		}
+	}
+	return s
+}
+func buildType(t reflect.Type, visited map[reflect.Type]bool) bson.M {
+	switch t {
+	case timeType, dateTimeType:
+		return bson.M{"bsonType": "date"}
+	case objectIdType:
+		return bson.M{"bsonType": "objectId"}
+	case decimalType:
+		return bson.M{"bsonType": "decimal"}
+	case uuidType, binaryType:
+		return bson.M{"bsonType": "binData"}
+	case bsonRawType:
+		return bson.M{"bsonType": "object"}
+	}
+	for _, m := range marshalerTypes {
+		if t.Implements(m) || reflect.PtrTo(t).Implements(m) {
+			return bson.M{}
+		}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return bson.M{"bsonType": "string"}
+	case reflect.Bool:
+		return bson.M{"bsonType": "bool"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return bson.M{"bsonType": "int"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		// int is stored as int32 when it fits, and as int64 when it does not
+		return bson.M{"bsonType": []string{"int", "long"}}
+	case reflect.Float32, reflect.Float64:
+		// number, because the other drivers can write the whole numbers as int or long
+		return bson.M{"bsonType": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return bson.M{"bsonType": "binData"}
+		}
+		return bson.M{"bsonType": "array", "items": buildField(t.Elem(), nil, visited)}
+	case reflect.Map:
+		return bson.M{"bsonType": "object"}
+	case reflect.Struct:
+		if mgo.IsNestedStruct(t) {
+			return buildObject(t, visited)
+		}
+		return bson.M{"bsonType": "object"}
+	}
+	return bson.M{}
+}
+
+// parseRules parses the validate tag, such as "required,max=50" or "oneof=a b c"
+func parseRules(tag string) map[string]string {
+	rules := make(map[string]string)
+	if len(tag) == 0 {
+		return rules
+	}
+	for _, r := range strings.Split(tag, ",") {
+		kv := strings.SplitN(strings.TrimSpace(r), "=", 2)
+		if len(kv) == 2 {
+			rules[kv[0]] = kv[1]
+		} else {
+			rules[kv[0]] = ""
+		}
+	}
+	return rules
+}
+func applyRules(s bson.M, t reflect.Type, rules map[string]string) {
+	if len(rules) == 0 {
+		return
+	}
+	switch t.Kind() {
+	case reflect.String:
+		setInt(s, "minLength", rules["min"])
+		setInt(s, "maxLength", rules["max"])
+		setInt(s, "minLength", rules["len"])
+		setInt(s, "maxLength", rules["len"])
+	case reflect.Slice, reflect.Array, reflect.Map:
+		if t.Kind() == reflect.Map {
+			return
+		}
+		setInt(s, "minItems", rules["min"])
+		setInt(s, "maxItems", rules["max"])
+		setInt(s, "minItems", rules["len"])
+		setInt(s, "maxItems", rules["len"])
+		return
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		setNumber(s, "minimum", rules["min"])
+		setNumber(s, "maximum", rules["max"])
+		setNumber(s, "minimum", rules["gte"])
+		setNumber(s, "maximum", rules["lte"])
+		if setNumber(s, "minimum", rules["gt"]) {
+			s["exclusiveMinimum"] = true
+		}
+		if setNumber(s, "maximum", rules["lt"]) {
+			s["exclusiveMaximum"] = true
+		}
+	default:
+		return
+	}
+	if v, ok := rules["oneof"]; ok && len(v) > 0 {
+		values := make([]interface{}, 0)
+		for _, x := range strings.Fields(v) {
+			if t.Kind() == reflect.String {
+				values = append(values, x)
+			} else if n, err := strconv.ParseFloat(x, 64); err == nil {
+				values = append(values, n)
+			}
+		}
+		s["enum"] = values
+	}
+}
+func setInt(s bson.M, key string, value string) {
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		s[key] = n
+	}
+}
+func setNumber(s bson.M, key string, value string) bool {
+	if n, err := strconv.ParseFloat(value, 64); err == nil {
+		s[key] = n
+		return true
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type address struct {
+	City string `bson:"city" validate:"max=50"`
+}
+type base struct {
+	CreatedAt time.Time `bson:"createdAt"`
+}
+type item struct {
+	Id       primitive.ObjectID `bson:"_id"`
+	Name     string             `bson:"name" validate:"required,min=1,max=100"`
+	Status   string             `bson:"status" validate:"oneof=A I"`
+	Age      int                `bson:"age,omitempty" validate:"gte=0,lt=150"`
+	Score    float64            `bson:"score" validate:"omitempty"`
+	Email    *string            `bson:"email" encrypt:"deterministic" validate:"max=50"`
+	Tags     []string           `bson:"tags" validate:"max=3"`
+	Address  address            `bson:"address"`
+	Data     []byte             `bson:"data,omitempty"`
+	Internal string             `bson:"-"`
+	base     `bson:",inline"`
+	Base     base `bson:",inline"`
+}
+
+func TestBuild(t *testing.T) {
+	s := Build(reflect.TypeOf(&item{}))
+	properties := s["properties"].(bson.M)
+	tests := []struct {
+		name string
+		want bson.M
+	}{
+		{"_id", bson.M{"bsonType": "objectId"}},
+		{"name", bson.M{"bsonType": "string", "minLength": int64(1), "maxLength": int64(100)}},
+		{"status", bson.M{"bsonType": "string", "enum": []interface{}{"A", "I"}}},
+		{"age", bson.M{"bsonType": []string{"int", "long"}, "minimum": float64(0), "maximum": float64(150), "exclusiveMaximum": true}},
+		{"score", bson.M{"bsonType": "number"}},
+		{"email", bson.M{"bsonType": []string{"string", "null"}}},
+		{"tags", bson.M{"bsonType": []string{"array", "null"}, "items": bson.M{"bsonType": "string"}, "maxItems": int64(3)}},
+		{"address", bson.M{"bsonType": "object", "properties": bson.M{"city": bson.M{"bsonType": "string", "maxLength": int64(50)}}, "required": []string{"city"}}},
+		{"data", bson.M{"bsonType": []string{"binData", "null"}}},
+		{"createdAt", bson.M{"bsonType": "date"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := properties[tt.name]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+	if _, ok := properties["Internal"]; ok {
+		t.Error("the field of bson:\"-\" must be skipped")
+	}
+	if len(properties) != len(tests) {
+		t.Errorf("len(properties) = %d, want %d", len(properties), len(tests))
+	}
+	want := []string{"_id", "name", "status", "tags", "address", "createdAt"}
+	if got := s["required"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("required = %v, want %v", got, want)
+	}
+}
+
+func TestBuildStringId(t *testing.T) {
+	type user struct {
+		Id   string `bson:"_id"`
+		Next *user  `bson:"next"`
+	}
+	properties := BuildSchema[user]()["properties"].(bson.M)
+	if got, want := properties["_id"], (bson.M{"bsonType": []string{"string", "objectId"}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("_id = %v, want %v", got, want)
+	}
+	if got, want := properties["next"], (bson.M{"bsonType": []string{"object", "null"}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("next = %v, want %v", got, want)
+	}
+}
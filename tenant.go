@@ -0,0 +1,160 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrNoTenant = errors.New("tenant is not found in context")
+
+// Tenant scopes the documents of a shared collection by the tenant id, which is read from ctx.Value(Key).
+// All calls without a tenant in ctx fail with ErrNoTenant.
+type Tenant struct {
+	Key   string
+	Index int
+	Json  string
+	Bson  string
+}
+
+// NewTenant creates the tenant scope of the model. field is the struct field name, or the bson name if the struct does not have this field.
+func NewTenant(modelType reflect.Type, key string, field string) *Tenant {
+	if modelType.Kind() == reflect.Ptr {
+		modelType = modelType.Elem()
+	}
+	t := &Tenant{Key: key, Index: -1, Json: field, Bson: field}
+	if sf, ok := modelType.FieldByName(field); ok && len(sf.Index) == 1 {
+		t.Index = sf.Index[0]
+		if tag, ok := sf.Tag.Lookup("json"); ok && len(strings.Split(tag, ",")[0]) > 0 {
+			t.Json = strings.Split(tag, ",")[0]
+		}
+		if tag, ok := sf.Tag.Lookup("bson"); ok && len(strings.Split(tag, ",")[0]) > 0 {
+			t.Bson = strings.Split(tag, ",")[0]
+		}
+	}
+	return t
+}
+
+// Get returns the tenant id of ctx. Empty strings are not valid tenant ids.
+func (t *Tenant) Get(ctx context.Context) (interface{}, error) {
+	return GetTenant(ctx, t.Key)
+}
+func GetTenant(ctx context.Context, key string) (interface{}, error) {
+	v := ctx.Value(key)
+	if v == nil {
+		return nil, ErrNoTenant
+	}
+	if s, ok := v.(string); ok && len(s) == 0 {
+		return nil, ErrNoTenant
+	}
+	return v, nil
+}
+
+// Filter appends the tenant condition to the filter
+func (t *Tenant) Filter(ctx context.Context, filter bson.D) (bson.D, error) {
+	id, err := t.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return append(filter, bson.E{Key: t.Bson, Value: id}), nil
+}
+func (t *Tenant) FilterMap(ctx context.Context, filter bson.M) (bson.M, error) {
+	id, err := t.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if filter == nil {
+		filter = bson.M{}
+	}
+	filter[t.Bson] = id
+	return filter, nil
+}
+
+// Stamp sets the tenant id to the model, which must be a pointer to struct
+func (t *Tenant) Stamp(ctx context.Context, model interface{}) error {
+	id, err := t.Get(ctx)
+	if err != nil {
+		return err
+	}
+	if t.Index < 0 {
+		return nil
+	}
+	vo := reflect.Indirect(reflect.ValueOf(model))
+	for vo.Kind() == reflect.Ptr {
+		vo = vo.Elem()
+	}
+	return setTenant(vo.Field(t.Index), id)
+}
+
+// StampMap sets the tenant id to the json map of patch, so that patch cannot move a document to another tenant
+func (t *Tenant) StampMap(ctx context.Context, model map[string]interface{}) error {
+	id, err := t.Get(ctx)
+	if err != nil {
+		return err
+	}
+	model[t.Json] = id
+	return nil
+}
+func setTenant(f reflect.Value, id interface{}) error {
+	v := reflect.ValueOf(id)
+	if f.Kind() == reflect.Ptr {
+		if !v.Type().ConvertibleTo(f.Type().Elem()) {
+			return errors.New("tenant id cannot be converted to " + f.Type().String())
+		}
+		p := reflect.New(f.Type().Elem())
+		p.Elem().Set(v.Convert(f.Type().Elem()))
+		f.Set(p)
+		return nil
+	}
+	if !v.Type().ConvertibleTo(f.Type()) {
+		return errors.New("tenant id cannot be converted to " + f.Type().String())
+	}
+	f.Set(v.Convert(f.Type()))
+	return nil
+}
+
+// TenantRouter is the database per tenant strategy. It creates and caches a service, such as a repository or a query, for the database of each tenant.
+type TenantRouter[R any] struct {
+	Client      *mongo.Client
+	Key         string
+	GetDatabase func(tenant interface{}) string
+	Create      func(db *mongo.Database) R
+	mu          sync.RWMutex
+	services    map[string]R
+}
+
+func NewTenantRouter[R any](client *mongo.Client, key string, getDatabase func(tenant interface{}) string, create func(db *mongo.Database) R) *TenantRouter[R] {
+	return &TenantRouter[R]{Client: client, Key: key, GetDatabase: getDatabase, Create: create, services: make(map[string]R)}
+}
+
+// Get returns the service of the tenant of ctx
+func (r *TenantRouter[R]) Get(ctx context.Context) (R, error) {
+	var res R
+	tenant, err := GetTenant(ctx, r.Key)
+	if err != nil {
+		return res, err
+	}
+	name := r.GetDatabase(tenant)
+	if len(name) == 0 {
+		return res, ErrNoTenant
+	}
+	r.mu.RLock()
+	res, ok := r.services[name]
+	r.mu.RUnlock()
+	if ok {
+		return res, nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if res, ok = r.services[name]; ok {
+		return res, nil
+	}
+	res = r.Create(r.Client.Database(name))
+	r.services[name] = res
+	return res, nil
+}
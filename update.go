@@ -0,0 +1,145 @@
+package mongo
+
+import (
+	"context"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Update builds the update document with the update operators. The field names are json names, which are translated to bson names by Build.
+type Update struct {
+	ops []operator
+}
+type operator struct {
+	name   string
+	fields bson.D
+}
+
+func NewUpdate() *Update {
+	return &Update{}
+}
+func (u *Update) Set(field string, value interface{}) *Update {
+	return u.add("$set", field, value)
+}
+func (u *Update) Unset(fields ...string) *Update {
+	for _, field := range fields {
+		u.add("$unset", field, "")
+	}
+	return u
+}
+func (u *Update) Inc(field string, value interface{}) *Update {
+	return u.add("$inc", field, value)
+}
+func (u *Update) Min(field string, value interface{}) *Update {
+	return u.add("$min", field, value)
+}
+func (u *Update) Max(field string, value interface{}) *Update {
+	return u.add("$max", field, value)
+}
+func (u *Update) Push(field string, value interface{}) *Update {
+	return u.add("$push", field, value)
+}
+
+// PushEach appends the values, which must be a slice. If slice is set, only the first (positive) or last (negative) elements are kept.
+func (u *Update) PushEach(field string, values interface{}, slice ...int) *Update {
+	each := bson.D{{Key: "$each", Value: values}}
+	if len(slice) > 0 {
+		each = append(each, bson.E{Key: "$slice", Value: slice[0]})
+	}
+	return u.add("$push", field, each)
+}
+
+// Pull removes the elements which are equal to the value, or match the value if it is a condition such as bson.M{"$gte": 6}
+func (u *Update) Pull(field string, value interface{}) *Update {
+	return u.add("$pull", field, value)
+}
+func (u *Update) AddToSet(field string, value interface{}) *Update {
+	return u.add("$addToSet", field, value)
+}
+func (u *Update) AddToSetEach(field string, values interface{}) *Update {
+	return u.add("$addToSet", field, bson.D{{Key: "$each", Value: values}})
+}
+
+// CurrentDate sets the field to the current date of the server
+func (u *Update) CurrentDate(field string) *Update {
+	return u.add("$currentDate", field, true)
+}
+func (u *Update) IsEmpty() bool {
+	return u == nil || len(u.ops) == 0
+}
+
+// Clone returns a copy, so that the fields can be added without changing the original
+func (u *Update) Clone() *Update {
+	c := &Update{}
+	if u == nil {
+		return c
+	}
+	for _, op := range u.ops {
+		fields := make(bson.D, len(op.fields))
+		copy(fields, op.fields)
+		c.ops = append(c.ops, operator{name: op.name, fields: fields})
+	}
+	return c
+}
+func (u *Update) add(name string, field string, value interface{}) *Update {
+	for i := range u.ops {
+		if u.ops[i].name == name {
+			for j := range u.ops[i].fields {
+				if u.ops[i].fields[j].Key == field {
+					u.ops[i].fields[j].Value = value
+					return u
+				}
+			}
+			u.ops[i].fields = append(u.ops[i].fields, bson.E{Key: field, Value: value})
+			return u
+		}
+	}
+	u.ops = append(u.ops, operator{name: name, fields: bson.D{{Key: field, Value: value}}})
+	return u
+}
+
+// Build returns the update document. The json names are translated to bson names by objectMap, which is made by MakeBsonMap.
+// If objectMap is nil, the field names are used as they are.
+func (u *Update) Build(objectMap map[string]string) bson.D {
+	doc := bson.D{}
+	if u == nil {
+		return doc
+	}
+	for _, op := range u.ops {
+		fields := bson.D{}
+		for _, e := range op.fields {
+			fields = append(fields, bson.E{Key: ToBsonName(objectMap, e.Key), Value: e.Value})
+		}
+		doc = append(doc, bson.E{Key: op.name, Value: fields})
+	}
+	return doc
+}
+
+// ToBsonName translates a json name in dot notation to the bson name. The parts which are not in objectMap, such as array indexes or "$", are kept.
+func ToBsonName(objectMap map[string]string, field string) string {
+	if objectMap == nil {
+		return field
+	}
+	if name, ok := objectMap[field]; ok {
+		return name
+	}
+	parts := strings.Split(field, ".")
+	for i := len(parts) - 1; i > 0; i-- {
+		if name, ok := objectMap[strings.Join(parts[:i], ".")]; ok {
+			return name + "." + strings.Join(parts[i:], ".")
+		}
+	}
+	return field
+}
+
+// ApplyOne updates a document by the update operators, and returns the matched count,
+// because operators such as $addToSet and $max can match a document without modifying it
+func ApplyOne(ctx context.Context, collection *mongo.Collection, filter interface{}, update interface{}) (int64, error) {
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+	return result.MatchedCount, nil
+}
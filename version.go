@@ -0,0 +1,135 @@
+package mongo
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Version fields can be int, int32, int64, time.Time (last modified time), primitive.ObjectID or string (UUID or ETag)
+
+// NewVersion returns the first version of a new document
+func NewVersion(versionType reflect.Type) (interface{}, bool) {
+	switch versionType.String() {
+	case "int":
+		return 1, true
+	case "int32":
+		return int32(1), true
+	case "int64":
+		return int64(1), true
+	case "time.Time":
+		return Now(), true
+	case "primitive.ObjectID":
+		return primitive.NewObjectID(), true
+	case "string":
+		return NewUUID(), true
+	default:
+		return nil, false
+	}
+}
+
+// NextVersion increases int versions by 1, and generates a new value for the other types. A time version is at least 1 millisecond after the current one
+func NextVersion(versionType reflect.Type, current interface{}) (interface{}, bool) {
+	switch v := current.(type) {
+	case int:
+		return v + 1, true
+	case int32:
+		return v + 1, true
+	case int64:
+		return v + 1, true
+	case time.Time:
+		// two writes in the same millisecond must not have the same version
+		next := Now()
+		if min := v.Add(time.Millisecond).Truncate(time.Millisecond); next.Before(min) {
+			next = min.UTC()
+		}
+		return next, true
+	}
+	switch versionType.String() {
+	case "time.Time", "primitive.ObjectID", "string":
+		return NewVersion(versionType)
+	default:
+		return nil, false
+	}
+}
+
+// ToVersion converts a version from a json map to the version type: float64 or string to int, RFC3339 string to time.Time, hex string to primitive.ObjectID
+func ToVersion(versionType reflect.Type, v interface{}) (interface{}, bool) {
+	if v == nil {
+		return nil, false
+	}
+	if reflect.TypeOf(v) == versionType {
+		return v, true
+	}
+	switch versionType.String() {
+	case "int", "int32", "int64":
+		var i int64
+		switch x := v.(type) {
+		case float64:
+			i = int64(x)
+		case int:
+			i = int64(x)
+		case int32:
+			i = int64(x)
+		case int64:
+			i = x
+		case string:
+			n, err := strconv.ParseInt(x, 10, 64)
+			if err != nil {
+				return nil, false
+			}
+			i = n
+		default:
+			return nil, false
+		}
+		return reflect.ValueOf(i).Convert(versionType).Interface(), true
+	case "time.Time":
+		s, ok := v.(string)
+		if !ok {
+			return nil, false
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, false
+		}
+		return t, true
+	case "primitive.ObjectID":
+		s, ok := v.(string)
+		if !ok {
+			return nil, false
+		}
+		oid, err := primitive.ObjectIDFromHex(s)
+		if err != nil {
+			return nil, false
+		}
+		return oid, true
+	}
+	return nil, false
+}
+
+// ETag formats a version to be sent in ETag http header
+func ETag(version interface{}) string {
+	switch v := version.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return strconv.Quote(v.UTC().Format(time.RFC3339Nano))
+	case primitive.ObjectID:
+		return strconv.Quote(v.Hex())
+	default:
+		return strconv.Quote(fmt.Sprintf("%v", v))
+	}
+}
+
+// Now returns the current time in milliseconds, which is the precision of BSON date, so that the version can be compared after it is stored
+func Now() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+// NewUUID generates a random (version 4) UUID string
+func NewUUID() string {
+	return GenerateUUID().String()
+}
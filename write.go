@@ -2,6 +2,7 @@ package mongo
 
 import (
 	"context"
+	"errors"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -10,45 +11,98 @@ import (
 	"strings"
 )
 
+// MakeBsonMap maps the json names to the bson names. The fields of nested structs are mapped in dot notation, such as "address.city",
+// and the fields of inline structs are mapped as the fields of the parent struct.
 func MakeBsonMap(modelType reflect.Type) map[string]string {
 	maps := make(map[string]string)
+	makeBsonMap(maps, modelType, "", "", map[reflect.Type]bool{})
+	return maps
+}
+func makeBsonMap(maps map[string]string, modelType reflect.Type, jsonPrefix string, bsonPrefix string, visited map[reflect.Type]bool) {
+	visited[modelType] = true
+	defer delete(visited, modelType)
 	numField := modelType.NumField()
 	for i := 0; i < numField; i++ {
 		field := modelType.Field(i)
 		key1 := field.Name
+		hasJson := false
 		if tag0, ok0 := field.Tag.Lookup("json"); ok0 {
-			if strings.Contains(tag0, ",") {
-				a := strings.Split(tag0, ",")
-				key1 = a[0]
-			} else {
-				key1 = tag0
+			key1 = strings.Split(tag0, ",")[0]
+			hasJson = len(key1) > 0 && key1 != "-"
+			if len(key1) == 0 {
+				key1 = field.Name
 			}
 		}
+		key2 := key1
+		inline := false
 		if tag, ok := field.Tag.Lookup("bson"); ok {
-			if tag != "-" {
-				if strings.Contains(tag, ",") {
-					a := strings.Split(tag, ",")
-					if key1 == "-" {
-						key1 = a[0]
-					}
-					maps[key1] = a[0]
-				} else {
-					if key1 == "-" {
-						key1 = tag
-					}
-					maps[key1] = tag
+			if tag == "-" {
+				continue
+			}
+			a := strings.Split(tag, ",")
+			key2 = a[0]
+			for _, o := range a[1:] {
+				if o == "inline" {
+					inline = true
 				}
 			}
-		} else {
-			if key1 == "-" {
-				key1 = field.Name
+			if len(key2) == 0 {
+				key2 = strings.ToLower(field.Name)
+			}
+		}
+		if key1 == "-" {
+			if key2 == "-" {
+				key2 = field.Name
 			}
-			maps[key1] = key1
+			key1 = key2
+		}
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if inline && fieldType.Kind() == reflect.Struct {
+			if !visited[fieldType] {
+				jsonPrefix2 := jsonPrefix
+				if !field.Anonymous || hasJson {
+					jsonPrefix2 = jsonPrefix + key1 + "."
+				}
+				makeBsonMap(maps, fieldType, jsonPrefix2, bsonPrefix, visited)
+			}
+			continue
+		}
+		maps[jsonPrefix+key1] = bsonPrefix + key2
+		if IsNestedStruct(fieldType) && !visited[fieldType] {
+			makeBsonMap(maps, fieldType, jsonPrefix+key1+".", bsonPrefix+key2+".", visited)
 		}
 	}
-	return maps
 }
 
+// IsNestedStruct returns false for the structs which are stored as single values, such as time.Time and decimal
+func IsNestedStruct(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	pkg := t.PkgPath()
+	if pkg == "time" || strings.HasPrefix(pkg, "go.mongodb.org/mongo-driver/") {
+		return false
+	}
+	pt := reflect.PtrTo(t)
+	if pt.Implements(bsonMarshalerType) || pt.Implements(bsonValueMarshalerType) {
+		return false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).IsExported() {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	bsonMarshalerType      = reflect.TypeOf((*bson.Marshaler)(nil)).Elem()
+	bsonValueMarshalerType = reflect.TypeOf((*bson.ValueMarshaler)(nil)).Elem()
+)
+
 func InsertOne(ctx context.Context, collection *mongo.Collection, model interface{}) (*primitive.ObjectID, int64, error) {
 	result, err := collection.InsertOne(ctx, model)
 	if err != nil {
@@ -66,16 +120,152 @@ func InsertOne(ctx context.Context, collection *mongo.Collection, model interfac
 	}
 }
 
-// For Patch
+// InsertOneWithError returns *DuplicateKeyError for a duplicate key, instead of 0 and nil error
+func InsertOneWithError(ctx context.Context, collection *mongo.Collection, model interface{}) (*primitive.ObjectID, int64, error) {
+	result, err := collection.InsertOne(ctx, model)
+	if err != nil {
+		if dup, ok := ToDuplicateKeyError(err); ok {
+			return nil, 0, dup
+		}
+		return nil, 0, err
+	}
+	if idValue, ok := result.InsertedID.(primitive.ObjectID); ok {
+		return &idValue, 1, nil
+	}
+	return nil, 1, nil
+}
+
+// For Patch. The nested maps of the nested structs are flattened to dot notation, so that only the fields in the map are updated.
 func MapToBson(object map[string]interface{}, objectMap map[string]string) map[string]interface{} {
 	result := make(map[string]interface{})
+	mapToBson(result, "", object, objectMap)
+	return result
+}
+func mapToBson(result map[string]interface{}, prefix string, object map[string]interface{}, objectMap map[string]string) {
 	for key, value := range object {
-		field, ok := objectMap[key]
+		path := prefix + key
+		if m, ok := value.(map[string]interface{}); ok && len(m) > 0 && hasSubFields(objectMap, path) {
+			mapToBson(result, path+".", m, objectMap)
+			continue
+		}
+		field, ok := objectMap[path]
 		if ok {
 			result[field] = value
 		}
 	}
-	return result
+}
+func hasSubFields(objectMap map[string]string, path string) bool {
+	prefix := path + "."
+	for k := range objectMap {
+		if strings.HasPrefix(k, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetNullParents replaces the fields in dot notation, of which a parent is null or not a document in the document of the filter, by the whole parent,
+// because $set cannot create a field in null, such as "address.city" if address is null.
+func SetNullParents(ctx context.Context, collection *mongo.Collection, filter interface{}, set map[string]interface{}) (map[string]interface{}, error) {
+	result, _, err := setNullParents(ctx, collection, filter, set)
+	return result, err
+}
+
+// setNullParents also returns the parents which are replaced, with their values which are read
+func setNullParents(ctx context.Context, collection *mongo.Collection, filter interface{}, set map[string]interface{}) (map[string]interface{}, bson.M, error) {
+	projection := bson.M{}
+	for k := range set {
+		if i := strings.Index(k, "."); i > 0 {
+			projection[k[:i]] = 1
+		}
+	}
+	if len(projection) == 0 {
+		return set, nil, nil
+	}
+	var doc bson.M
+	if err := collection.FindOne(ctx, filter, options.FindOne().SetProjection(projection)).Decode(&doc); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return set, nil, nil
+		}
+		return nil, nil, err
+	}
+	result := make(map[string]interface{})
+	parents := bson.M{}
+	for k, v := range set {
+		path := strings.Split(k, ".")
+		parent := -1
+		var current interface{} = doc
+		var value interface{}
+		for i := 0; i < len(path)-1; i++ {
+			m, ok := current.(bson.M)
+			if !ok {
+				break
+			}
+			x, exist := m[path[i]]
+			if !exist {
+				break
+			}
+			if _, isDoc := x.(bson.M); !isDoc {
+				parent = i
+				value = x
+				break
+			}
+			current = x
+		}
+		if parent < 0 {
+			result[k] = v
+			continue
+		}
+		key := strings.Join(path[:parent+1], ".")
+		parents[key] = value
+		sub, ok := result[key].(map[string]interface{})
+		if !ok {
+			sub = make(map[string]interface{})
+			result[key] = sub
+		}
+		for _, p := range path[parent+1 : len(path)-1] {
+			next, ok := sub[p].(map[string]interface{})
+			if !ok {
+				next = make(map[string]interface{})
+				sub[p] = next
+			}
+			sub = next
+		}
+		sub[path[len(path)-1]] = v
+	}
+	return result, parents, nil
+}
+
+// retryNullParents retries the update, if it fails because a parent is null, with the $set by SetNullParents.
+// The filter of the retry has the values of the parents which are read, so that a parent which is written after the read is not replaced.
+// In that case, nothing matches, and the update is tried again. update returns true if a document matches.
+func retryNullParents(ctx context.Context, collection *mongo.Collection, filter interface{}, model interface{}, err error, update func(filter interface{}, set map[string]interface{}) (bool, error)) error {
+	set, ok := model.(map[string]interface{})
+	if m, isM := model.(bson.M); isM {
+		set, ok = m, true
+	}
+	if !ok {
+		return err
+	}
+	for i := 0; i < 5 && isNullParentError(err); i++ {
+		fixed, parents, er1 := setNullParents(ctx, collection, filter, set)
+		if er1 != nil || len(parents) == 0 {
+			return err
+		}
+		var matched bool
+		matched, err = update(bson.D{{Key: "$and", Value: bson.A{filter, parents}}}, fixed)
+		if matched || err != nil && err != mongo.ErrNoDocuments {
+			return err
+		}
+		_, err = update(filter, set)
+	}
+	return err
+}
+
+// isNullParentError checks if $set cannot create a field in dot notation, because a parent is null or not a document
+func isNullParentError(err error) bool {
+	var se mongo.ServerError
+	return errors.As(err, &se) && se.HasErrorCode(28)
 }
 func PatchOne(ctx context.Context, collection *mongo.Collection, id interface{}, model map[string]interface{}) (int64, error) {
 	filter := bson.M{"_id": id}
@@ -83,6 +273,11 @@ func PatchOne(ctx context.Context, collection *mongo.Collection, id interface{},
 		"$set": model,
 	}
 	result, err := collection.UpdateOne(ctx, filter, updateQuery)
+	err = retryNullParents(ctx, collection, filter, model, err, func(filter interface{}, set map[string]interface{}) (bool, error) {
+		var er1 error
+		result, er1 = collection.UpdateOne(ctx, filter, bson.M{"$set": set})
+		return er1 == nil && result.MatchedCount > 0, er1
+	})
 	if err != nil {
 		return 0, err
 	}
@@ -99,6 +294,14 @@ func PatchOneByFilter(ctx context.Context, collection *mongo.Collection, filter
 		"$set": model,
 	}
 	result, err := collection.UpdateOne(ctx, filter, updateQuery)
+	err = retryNullParents(ctx, collection, filter, model, err, func(filter interface{}, set map[string]interface{}) (bool, error) {
+		var er1 error
+		result, er1 = collection.UpdateOne(ctx, filter, bson.M{"$set": set})
+		return er1 == nil && result.MatchedCount > 0, er1
+	})
+	if err != nil {
+		return 0, err
+	}
 	if result.ModifiedCount > 0 {
 		return result.ModifiedCount, err
 	} else if result.UpsertedCount > 0 {
@@ -113,6 +316,14 @@ func UpdateOne(ctx context.Context, collection *mongo.Collection, id interface{}
 		"$set": model,
 	}
 	result, err := collection.UpdateOne(ctx, filter, updateQuery)
+	err = retryNullParents(ctx, collection, filter, model, err, func(filter interface{}, set map[string]interface{}) (bool, error) {
+		var er1 error
+		result, er1 = collection.UpdateOne(ctx, filter, bson.M{"$set": set})
+		return er1 == nil && result.MatchedCount > 0, er1
+	})
+	if err != nil {
+		return 0, err
+	}
 	if result.ModifiedCount > 0 {
 		return result.ModifiedCount, err
 	} else if result.UpsertedCount > 0 {
@@ -126,6 +337,14 @@ func UpdateOneByFilter(ctx context.Context, collection *mongo.Collection, filter
 		"$set": model,
 	}
 	result, err := collection.UpdateOne(ctx, filter, updateQuery)
+	err = retryNullParents(ctx, collection, filter, model, err, func(filter interface{}, set map[string]interface{}) (bool, error) {
+		var er1 error
+		result, er1 = collection.UpdateOne(ctx, filter, bson.M{"$set": set})
+		return er1 == nil && result.MatchedCount > 0, er1
+	})
+	if err != nil {
+		return 0, err
+	}
 	if result.ModifiedCount > 0 {
 		return result.ModifiedCount, err
 	} else if result.UpsertedCount > 0 {
@@ -141,6 +360,9 @@ func UpsertOne(ctx context.Context, collection *mongo.Collection, id interface{}
 	}
 	opts := options.Update().SetUpsert(true)
 	res, err := collection.UpdateOne(ctx, filter, updateQuery, opts)
+	if err != nil {
+		return 0, err
+	}
 	if res.ModifiedCount > 0 {
 		return res.ModifiedCount, err
 	} else if res.UpsertedCount > 0 {
@@ -155,6 +377,27 @@ func UpsertOneByFilter(ctx context.Context, collection *mongo.Collection, filter
 	}
 	opts := options.Update().SetUpsert(true)
 	res, err := collection.UpdateOne(ctx, filter, updateQuery, opts)
+	if err != nil {
+		return 0, err
+	}
+	if res.ModifiedCount > 0 {
+		return res.ModifiedCount, err
+	} else if res.UpsertedCount > 0 {
+		return res.UpsertedCount, err
+	} else {
+		return res.MatchedCount, err
+	}
+}
+func UpsertOneWithSetOnInsert(ctx context.Context, collection *mongo.Collection, filter bson.D, model interface{}, onInsert interface{}) (int64, error) {
+	updateQuery := bson.M{
+		"$set":         model,
+		"$setOnInsert": onInsert,
+	}
+	opts := options.Update().SetUpsert(true)
+	res, err := collection.UpdateOne(ctx, filter, updateQuery, opts)
+	if err != nil {
+		return 0, err
+	}
 	if res.ModifiedCount > 0 {
 		return res.ModifiedCount, err
 	} else if res.UpsertedCount > 0 {
@@ -163,6 +406,95 @@ func UpsertOneByFilter(ctx context.Context, collection *mongo.Collection, filter
 		return res.MatchedCount, err
 	}
 }
+
+// UpdateOneAndGet sets the fields of model to the document of the filter, and decodes the document after the update into result. It returns 0 if no document matches.
+func UpdateOneAndGet(ctx context.Context, collection *mongo.Collection, filter bson.D, model interface{}, result interface{}) (int64, error) {
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := collection.FindOneAndUpdate(ctx, filter, bson.M{"$set": model}, opts).Decode(result)
+	err = retryNullParents(ctx, collection, filter, model, err, func(filter interface{}, set map[string]interface{}) (bool, error) {
+		er1 := collection.FindOneAndUpdate(ctx, filter, bson.M{"$set": set}, opts).Decode(result)
+		return er1 == nil, er1
+	})
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+// UpsertOneAndGet upserts the document of the filter by the update operators, decodes the document after the update into result, and returns true if the document is inserted.
+// The existing document is updated and returned in one round trip. Only if no document matches, it is upserted, and read back by the upserted id.
+func UpsertOneAndGet(ctx context.Context, collection *mongo.Collection, filter bson.D, update bson.M, result interface{}) (bool, error) {
+	err := collection.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(result)
+	if err != mongo.ErrNoDocuments {
+		return false, err
+	}
+	res, err := collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return false, err
+	}
+	if res.UpsertedID != nil {
+		return true, collection.FindOne(ctx, bson.D{{Key: "_id", Value: res.UpsertedID}}).Decode(result)
+	}
+	// the document is inserted by another writer between the two updates, and may not match the filter after the update
+	var byId interface{} = filter
+	for _, e := range filter {
+		if e.Key == "_id" {
+			byId = bson.D{e}
+		}
+	}
+	return false, collection.FindOne(ctx, byId).Decode(result)
+}
+
+// InsertOneAndGet inserts the model by FindOneAndUpdate with upsert on the _id, and decodes the inserted document into result, in one round trip.
+// Like InsertOne, the ObjectID is generated if the model has no _id, and is returned. If the _id exists, it returns *DuplicateKeyError.
+func InsertOneAndGet(ctx context.Context, collection *mongo.Collection, model interface{}, result interface{}) (*primitive.ObjectID, int64, error) {
+	b, err := bson.Marshal(model)
+	if err != nil {
+		return nil, 0, err
+	}
+	var doc bson.D
+	if err = bson.Unmarshal(b, &doc); err != nil {
+		return nil, 0, err
+	}
+	var rid *primitive.ObjectID
+	var id interface{}
+	fields := bson.D{}
+	for _, e := range doc {
+		if e.Key == "_id" {
+			id = e.Value
+		} else {
+			fields = append(fields, e)
+		}
+	}
+	if id == nil {
+		oid := primitive.NewObjectID()
+		rid, id = &oid, oid
+	}
+	filter := bson.D{{Key: "_id", Value: id}}
+	onInsert := fields
+	if len(onInsert) == 0 {
+		onInsert = filter
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before)
+	err = collection.FindOneAndUpdate(ctx, filter, bson.M{"$setOnInsert": onInsert}, opts).Err()
+	if err == nil {
+		return nil, 0, &DuplicateKeyError{Index: "_id_", Keys: map[string]interface{}{"_id": id}}
+	}
+	if err != mongo.ErrNoDocuments {
+		if dup, ok := ToDuplicateKeyError(err); ok {
+			return nil, 0, dup
+		}
+		return nil, 0, err
+	}
+	// the inserted document is the _id of the filter and the fields of $setOnInsert
+	if b, err = bson.Marshal(append(filter, fields...)); err != nil {
+		return rid, 1, err
+	}
+	return rid, 1, bson.Unmarshal(b, result)
+}
 func DeleteOne(ctx context.Context, collection *mongo.Collection, id interface{}) (int64, error) {
 	filter := bson.M{"_id": id}
 	result, err := collection.DeleteOne(ctx, filter)
@@ -171,3 +503,10 @@ func DeleteOne(ctx context.Context, collection *mongo.Collection, id interface{}
 	}
 	return result.DeletedCount, err
 }
+func DeleteOneByFilter(ctx context.Context, collection *mongo.Collection, filter interface{}) (int64, error) {
+	result, err := collection.DeleteOne(ctx, filter)
+	if result == nil {
+		return 0, err
+	}
+	return result.DeletedCount, err
+}
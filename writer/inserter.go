@@ -3,11 +3,15 @@ package writer
 import (
 	"context"
 	"go.mongodb.org/mongo-driver/mongo"
+
+	mgo "github.com/core-go/mongo"
 )
 
 type Inserter[T any] struct {
 	collection *mongo.Collection
 	Map        func(T)
+	Hooks      *mgo.Hooks[T]
+	mgo.Options
 }
 
 func NewInserter[T any](database *mongo.Database, collectionName string, options ...func(T)) *Inserter[T] {
@@ -16,14 +20,24 @@ func NewInserter[T any](database *mongo.Database, collectionName string, options
 		mp = options[0]
 	}
 	collection := database.Collection(collectionName)
-	return &Inserter[T]{collection: collection, Map: mp}
+	w := &Inserter[T]{collection: collection, Map: mp}
+	w.Options = mgo.NewOptions(&w.collection)
+	return w
 }
 
 func (w *Inserter[T]) Write(ctx context.Context, model T) error {
+	ctx, cancel := mgo.WithTimeout(ctx, w.Timeout())
+	defer cancel()
 	var err error
+	if err = mgo.RunBeforeCreate(ctx, w.Hooks, &model); err != nil {
+		return err
+	}
 	if w.Map != nil {
 		w.Map(model)
 	}
 	_, err = w.collection.InsertOne(ctx, model)
-	return err
+	if err != nil {
+		return err
+	}
+	return mgo.RunAfterCreate(ctx, w.Hooks, &model)
 }
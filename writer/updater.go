@@ -5,13 +5,17 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"reflect"
+
+	mgo "github.com/core-go/mongo"
 )
 
 type Updater[T any] struct {
 	collection *mongo.Collection
 	idIndex    int
 	Map        func(T)
+	Hooks      *mgo.Hooks[T]
 	isPointer  bool
+	mgo.Options
 }
 
 func NewUpdater[T any](database *mongo.Database, collectionName string, options ...func(T)) *Updater[T] {
@@ -28,10 +32,17 @@ func NewUpdater[T any](database *mongo.Database, collectionName string, options
 	}
 	index := FindIdField(modelType)
 	collection := database.Collection(collectionName)
-	return &Updater[T]{collection: collection, idIndex: index, Map: mp, isPointer: isPointer}
+	w := &Updater[T]{collection: collection, idIndex: index, Map: mp, isPointer: isPointer}
+	w.Options = mgo.NewOptions(&w.collection)
+	return w
 }
 
 func (w *Updater[T]) Write(ctx context.Context, model T) error {
+	ctx, cancel := mgo.WithTimeout(ctx, w.Timeout())
+	defer cancel()
+	if err := mgo.RunBeforeUpdate(ctx, w.Hooks, &model); err != nil {
+		return err
+	}
 	if w.Map != nil {
 		w.Map(model)
 	}
@@ -40,7 +51,10 @@ func (w *Updater[T]) Write(ctx context.Context, model T) error {
 		vo = reflect.Indirect(vo)
 	}
 	id := vo.Field(w.idIndex).Interface()
-	return Update(ctx, w.collection, id, model)
+	if err := Update(ctx, w.collection, id, model); err != nil {
+		return err
+	}
+	return mgo.RunAfterUpdate(ctx, w.Hooks, &model)
 }
 
 func Update(ctx context.Context, collection *mongo.Collection, id interface{}, model interface{}) error { //Patch
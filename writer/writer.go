@@ -6,13 +6,17 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"reflect"
+
+	mgo "github.com/core-go/mongo"
 )
 
 type Writer[T any] struct {
 	collection *mongo.Collection
 	idIndex    int
 	Map        func(T)
+	Hooks      *mgo.Hooks[T]
 	isPointer  bool
+	mgo.Options
 }
 
 func NewWriter[T any](database *mongo.Database, collectionName string, options ...func(T)) *Writer[T] {
@@ -29,24 +33,41 @@ func NewWriter[T any](database *mongo.Database, collectionName string, options .
 	}
 	index := FindIdField(modelType)
 	collection := database.Collection(collectionName)
-	return &Writer[T]{collection: collection, idIndex: index, Map: mp, isPointer: isPointer}
+	w := &Writer[T]{collection: collection, idIndex: index, Map: mp, isPointer: isPointer}
+	w.Options = mgo.NewOptions(&w.collection)
+	return w
 }
 
 func (w *Writer[T]) Write(ctx context.Context, model T) error {
-	if w.Map != nil {
-		w.Map(model)
-	}
+	ctx, cancel := mgo.WithTimeout(ctx, w.Timeout())
+	defer cancel()
 	vo := reflect.ValueOf(model)
 	if w.isPointer {
 		vo = reflect.Indirect(vo)
 	}
 	id := vo.Field(w.idIndex).Interface()
-	sid, ok := id.(string)
-	if ok && len(sid) == 0 || isNil(id) {
-		_, err := w.collection.InsertOne(ctx, model)
+	if mgo.IsEmptyId(id) {
+		if err := mgo.RunBeforeCreate(ctx, w.Hooks, &model); err != nil {
+			return err
+		}
+		if w.Map != nil {
+			w.Map(model)
+		}
+		if _, err := w.collection.InsertOne(ctx, model); err != nil {
+			return err
+		}
+		return mgo.RunAfterCreate(ctx, w.Hooks, &model)
+	}
+	if err := mgo.RunBeforeUpdate(ctx, w.Hooks, &model); err != nil {
 		return err
 	}
-	return Upsert(ctx, w.collection, id, model)
+	if w.Map != nil {
+		w.Map(model)
+	}
+	if err := Upsert(ctx, w.collection, id, model); err != nil {
+		return err
+	}
+	return mgo.RunAfterUpdate(ctx, w.Hooks, &model)
 }
 
 func Upsert(ctx context.Context, collection *mongo.Collection, id interface{}, model interface{}) error {
@@ -58,13 +79,3 @@ func Upsert(ctx context.Context, collection *mongo.Collection, id interface{}, m
 	_, err := collection.UpdateOne(ctx, filter, updateQuery, opts)
 	return err
 }
-func isNil(i interface{}) bool {
-	if i == nil {
-		return true
-	}
-	switch reflect.TypeOf(i).Kind() {
-	case reflect.Ptr:
-		return reflect.ValueOf(i).IsNil()
-	}
-	return false
-}